@@ -0,0 +1,5 @@
+module github.com/guilherme13c/go-search/cdxj
+
+go 1.24.2
+
+require github.com/guilherme13c/go-search/utils v0.0.0-20250428124800-cd45620b364c
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/cdxj"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "index":
+		err = runIndex(os.Args[2:])
+	case "lookup":
+		err = runLookup(os.Args[2:])
+	case "duplicates":
+		err = runDuplicates(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  cdxj index [-o index.cdxj] file.warc.gz...")
+	fmt.Fprintln(os.Stderr, "  cdxj lookup -index index.cdxj [-dir corpus] [-fetch] url")
+	fmt.Fprintln(os.Stderr, "  cdxj duplicates -index index.cdxj")
+	os.Exit(2)
+}
+
+func runIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	output := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	entries := []cdxj.Entry{}
+	for _, path := range fs.Args() {
+		e, err := cdxj.IndexFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e...)
+	}
+	cdxj.Sort(entries)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output error: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return cdxj.Write(w, entries)
+}
+
+func runLookup(args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	indexPath := fs.String("index", "index.cdxj", "sorted CDXJ index")
+	dir := fs.String("dir", "corpus", "directory holding the WARC files")
+	fetch := fs.Bool("fetch", false, "write the payload of the latest capture to stdout, taken from the original for a revisit")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	idx, err := cdxj.Open(*indexPath)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	entries, err := idx.Lookup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no captures for %s", fs.Arg(0))
+	}
+
+	if !*fetch {
+		for _, e := range entries {
+			fmt.Println(e.String())
+		}
+		return nil
+	}
+
+	rec, closer, err := cdxj.Resolve(idx, *dir, entries[len(entries)-1])
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	if _, err := io.Copy(os.Stdout, rec.Payload); err != nil {
+		return fmt.Errorf("write payload error: %w", err)
+	}
+
+	return nil
+}
+
+// runDuplicates prints one line per body archived under several URLs: its
+// digest, the URL holding the payload, then the URLs that duplicate it.
+func runDuplicates(args []string) error {
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	indexPath := fs.String("index", "index.cdxj", "CDXJ index")
+	fs.Parse(args)
+
+	file, err := os.Open(*indexPath)
+	if err != nil {
+		return fmt.Errorf("open error: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := cdxj.Read(file)
+	if err != nil {
+		return err
+	}
+	for _, c := range cdxj.Duplicates(entries) {
+		fmt.Println(c.Digest + " " + strings.Join(c.URLs(), " "))
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/guilherme13c/go-search/utils/set"
+)
+
+type canonicalIndex struct {
+	mu       sync.Mutex
+	stored   set.Set[string]
+	clusters map[string][]string
+}
+
+func newCanonicalIndex() *canonicalIndex {
+	return &canonicalIndex{
+		mu:       sync.Mutex{},
+		stored:   set.NewSet[string](),
+		clusters: make(map[string][]string),
+	}
+}
+
+// Covered reports whether pageUrl names another page as canonical and that
+// page is already in the corpus, so pageUrl need not be stored. It is then
+// counted in that page's cluster.
+func (self *canonicalIndex) Covered(pageUrl string, canonical string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if canonical == "" || canonical == pageUrl || !self.stored.Contains(canonical) {
+		return false
+	}
+	self.join(pageUrl, canonical)
+
+	return true
+}
+
+// Add records pageUrl as stored, in the cluster of its canonical URL. It is
+// called once the page's records are written.
+func (self *canonicalIndex) Add(pageUrl string, canonical string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if canonical == "" {
+		canonical = pageUrl
+	}
+	self.stored.Add(pageUrl)
+	self.join(pageUrl, canonical)
+}
+
+func (self *canonicalIndex) join(pageUrl string, canonical string) {
+	if !slices.Contains(self.clusters[canonical], pageUrl) {
+		self.clusters[canonical] = append(self.clusters[canonical], pageUrl)
+	}
+}
+
+func (self *canonicalIndex) Snapshot() ([]byte, map[string][]string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	stored, err := self.stored.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters := make(map[string][]string, len(self.clusters))
+	for canonical, urls := range self.clusters {
+		clusters[canonical] = append([]string(nil), urls...)
+	}
+
+	return stored, clusters, nil
+}
+
+func (self *canonicalIndex) Restore(stored []byte, clusters map[string][]string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if err := self.stored.UnmarshalBinary(stored); err != nil {
+		return err
+	}
+	for canonical, urls := range clusters {
+		for _, pageUrl := range urls {
+			self.join(pageUrl, canonical)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanonicalIndex(t *testing.T) {
+	c := newCanonicalIndex()
+	canonical := "https://example.com/page"
+
+	if c.Covered("https://example.com/page?ref=a", canonical) {
+		t.Fatal("Covered before the canonical page is stored = true; want false")
+	}
+	c.Add("https://example.com/page?ref=a", canonical)
+	if c.Covered(canonical, canonical) {
+		t.Fatal("Covered(canonical page) = true; want it stored")
+	}
+	c.Add(canonical, canonical)
+
+	for range 2 {
+		if !c.Covered("https://example.com/page?ref=b", canonical) {
+			t.Fatal("Covered after the canonical page is stored = false; want true")
+		}
+	}
+
+	_, clusters, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot error = %v", err)
+	}
+	want := []string{"https://example.com/page?ref=a", canonical, "https://example.com/page?ref=b"}
+	if got := clusters[canonical]; !slices.Equal(got, want) {
+		t.Errorf("cluster = %v; want %v, each page once", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+type captureIndex struct {
+	mu    sync.Mutex
+	byURL map[string]*warc.Record
+}
+
+func newCaptureIndex() *captureIndex {
+	return &captureIndex{
+		mu:    sync.Mutex{},
+		byURL: make(map[string]*warc.Record),
+	}
+}
+
+func (self *captureIndex) Get(targetURI string) (*warc.Record, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	rec, ok := self.byURL[targetURI]
+	return rec, ok
+}
+
+func (self *captureIndex) Lookup(targetURI string, payloadDigest string) (*warc.Record, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	rec, ok := self.byURL[targetURI]
+	if !ok || rec.Header.Get(warc.FieldPayloadDigest) != payloadDigest {
+		return nil, false
+	}
+
+	return rec, true
+}
+
+func (self *captureIndex) Add(response *warc.Record) *warc.Record {
+	original := originalOf(response)
+	self.Set(original.Header.Get(warc.FieldTargetURI), original)
+
+	return original
+}
+
+// Set makes original the capture of targetURI, which for a duplicate body is
+// the response archived under another URL.
+func (self *captureIndex) Set(targetURI string, original *warc.Record) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.byURL[targetURI] = original
+}
+
+func (self *captureIndex) Snapshot() map[string][]warc.Field {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make(map[string][]warc.Field, len(self.byURL))
+	for targetURI, rec := range self.byURL {
+		res[targetURI] = rec.Header.Fields()
+	}
+
+	return res
+}
+
+func (self *captureIndex) Restore(captures map[string][]warc.Field) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for targetURI, fields := range captures {
+		original := &warc.Record{}
+		for _, f := range fields {
+			original.Header.Add(f.Name, f.Value)
+		}
+		self.byURL[targetURI] = original
+	}
+}
+
+// originalOf keeps the header fields a revisit record needs to refer to
+// response, so the payload is not held in memory.
+func originalOf(response *warc.Record) *warc.Record {
+	original := &warc.Record{}
+	for _, name := range []string{
+		warc.FieldType,
+		warc.FieldRecordID,
+		warc.FieldDate,
+		warc.FieldTargetURI,
+		warc.FieldPayloadDigest,
+	} {
+		original.Header.Set(name, response.Header.Get(name))
+	}
+
+	return original
+}
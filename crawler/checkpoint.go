@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guilherme13c/go-search/utils/dedup"
+	"github.com/guilherme13c/go-search/utils/recrawl"
+	"github.com/guilherme13c/go-search/utils/retry"
+	robotstxt "github.com/guilherme13c/go-search/utils/robots-txt"
+	"github.com/guilherme13c/go-search/utils/traps"
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+const checkpointFile = "state.gob"
+
+type robotsEntry struct {
+	Domain string
+	Rules  robotstxt.Robotstxt
+}
+
+type checkpoint struct {
+	Time           time.Time
+	Reason         string
+	Pages          int64
+	Bytes          int64
+	WarcSerial     int
+	Frontier       []frontierItem
+	Visited        []byte
+	Robots         []robotsEntry
+	Captures       map[string][]warc.Field
+	Canonicals     []byte
+	Clusters       map[string][]string
+	Duplicates     map[string][]string
+	NearDuplicates []dedup.Entry
+	NearDupGroups  map[string][]string
+	History        map[string]pageState
+	Schedule       []recrawl.PageHistory
+	Traps          traps.State
+	Breaker        []retry.HostState
+}
+
+func writeCheckpoint(dir string, cp checkpoint) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create checkpoint dir error: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, checkpointFile+".*")
+	if err != nil {
+		return fmt.Errorf("create checkpoint error: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(cp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode checkpoint error: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync checkpoint error: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close checkpoint error: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, checkpointFile)); err != nil {
+		return fmt.Errorf("rename checkpoint error: %w", err)
+	}
+
+	return nil
+}
+
+func readCheckpoint(dir string) (checkpoint, error) {
+	var cp checkpoint
+
+	file, err := os.Open(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return cp, fmt.Errorf("open checkpoint error: %w", err)
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(&cp); err != nil {
+		return cp, fmt.Errorf("decode checkpoint error: %w", err)
+	}
+
+	return cp, nil
+}
+
+// Files written after the last checkpoint still hold serials, so the next one
+// is taken from whatever is on disk as well as from the checkpoint.
+func nextWarcSerial(dir string, prefix string) (int, error) {
+	names, err := filepath.Glob(filepath.Join(dir, prefix+"-*.warc*"))
+	if err != nil {
+		return 0, fmt.Errorf("list WARC files error: %w", err)
+	}
+
+	next := 0
+	for _, name := range names {
+		parts := strings.SplitN(strings.TrimPrefix(filepath.Base(name), prefix+"-"), "-", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		serial, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		next = max(next, serial+1)
+	}
+
+	return next, nil
+}
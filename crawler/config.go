@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/guilherme13c/go-search/utils/scope"
+	"github.com/guilherme13c/go-search/utils/traps"
+)
+
+const envPrefix = "GO_SEARCH_"
+
+type duration time.Duration
+
+func (self duration) String() string {
+	return time.Duration(self).String()
+}
+
+func (self *duration) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*self = duration(d)
+
+	return nil
+}
+
+func (self duration) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+func (self *duration) UnmarshalText(b []byte) error {
+	return self.Set(string(b))
+}
+
+type stringList []string
+
+func (self stringList) String() string {
+	return strings.Join(self, ",")
+}
+
+func (self *stringList) Set(s string) error {
+	*self = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*self = append(*self, v)
+		}
+	}
+
+	return nil
+}
+
+type config struct {
+	SeedsPath          string     `json:"seeds" yaml:"seeds" toml:"seeds"`
+	OutputDir          string     `json:"output_dir" yaml:"output_dir" toml:"output_dir"`
+	Reset              bool       `json:"reset" yaml:"reset" toml:"reset"`
+	Workers            int        `json:"workers" yaml:"workers" toml:"workers"`
+	FetchTimeout       duration   `json:"fetch_timeout" yaml:"fetch_timeout" toml:"fetch_timeout"`
+	DownloadTimeout    duration   `json:"download_timeout" yaml:"download_timeout" toml:"download_timeout"`
+	MaxBodySize        int64      `json:"max_body_size" yaml:"max_body_size" toml:"max_body_size"`
+	RobotsTimeout      duration   `json:"robots_timeout" yaml:"robots_timeout" toml:"robots_timeout"`
+	UserAgent          string     `json:"user_agent" yaml:"user_agent" toml:"user_agent"`
+	From               string     `json:"from" yaml:"from" toml:"from"`
+	MaxConnsPerHost    int        `json:"max_conns_per_host" yaml:"max_conns_per_host" toml:"max_conns_per_host"`
+	IdleConnsPerHost   int        `json:"idle_conns_per_host" yaml:"idle_conns_per_host" toml:"idle_conns_per_host"`
+	IdleConnTimeout    duration   `json:"idle_conn_timeout" yaml:"idle_conn_timeout" toml:"idle_conn_timeout"`
+	MaxConnsPerIP      int        `json:"max_conns_per_ip" yaml:"max_conns_per_ip" toml:"max_conns_per_ip"`
+	DNSServers         stringList `json:"dns_servers" yaml:"dns_servers" toml:"dns_servers"`
+	DNSTimeout         duration   `json:"dns_timeout" yaml:"dns_timeout" toml:"dns_timeout"`
+	DNSCacheSize       uint       `json:"dns_cache_size" yaml:"dns_cache_size" toml:"dns_cache_size"`
+	DNSMinTTL          duration   `json:"dns_min_ttl" yaml:"dns_min_ttl" toml:"dns_min_ttl"`
+	DNSMaxTTL          duration   `json:"dns_max_ttl" yaml:"dns_max_ttl" toml:"dns_max_ttl"`
+	DNSNegativeTTL     duration   `json:"dns_negative_ttl" yaml:"dns_negative_ttl" toml:"dns_negative_ttl"`
+	RobotsCacheSize    uint       `json:"robots_cache_size" yaml:"robots_cache_size" toml:"robots_cache_size"`
+	RobotsErrorTTL     duration   `json:"robots_error_ttl" yaml:"robots_error_ttl" toml:"robots_error_ttl"`
+	WarcPrefix         string     `json:"warc_prefix" yaml:"warc_prefix" toml:"warc_prefix"`
+	WarcMaxSize        int64      `json:"warc_max_size" yaml:"warc_max_size" toml:"warc_max_size"`
+	MaxPages           int64      `json:"max_pages" yaml:"max_pages" toml:"max_pages"`
+	MaxBytes           int64      `json:"max_bytes" yaml:"max_bytes" toml:"max_bytes"`
+	MaxDuration        duration   `json:"max_duration" yaml:"max_duration" toml:"max_duration"`
+	MaxDepth           int        `json:"max_depth" yaml:"max_depth" toml:"max_depth"`
+	MaxRedirects       int        `json:"max_redirects" yaml:"max_redirects" toml:"max_redirects"`
+	CheckpointDir      string     `json:"checkpoint_dir" yaml:"checkpoint_dir" toml:"checkpoint_dir"`
+	CheckpointInterval duration   `json:"checkpoint_interval" yaml:"checkpoint_interval" toml:"checkpoint_interval"`
+	Resume             bool       `json:"resume" yaml:"resume" toml:"resume"`
+	Recrawl            bool       `json:"recrawl" yaml:"recrawl" toml:"recrawl"`
+	RecrawlBudget      float64    `json:"recrawl_budget" yaml:"recrawl_budget" toml:"recrawl_budget"`
+	RecrawlMinInterval duration   `json:"recrawl_min_interval" yaml:"recrawl_min_interval" toml:"recrawl_min_interval"`
+	RecrawlMaxInterval duration   `json:"recrawl_max_interval" yaml:"recrawl_max_interval" toml:"recrawl_max_interval"`
+	RecrawlFirstVisit  duration   `json:"recrawl_first_visit" yaml:"recrawl_first_visit" toml:"recrawl_first_visit"`
+	Scope              string     `json:"scope" yaml:"scope" toml:"scope"`
+	ScopeInclude       stringList `json:"scope_include" yaml:"scope_include" toml:"scope_include"`
+	ScopeExclude       stringList `json:"scope_exclude" yaml:"scope_exclude" toml:"scope_exclude"`
+	BlockedExtensions  stringList `json:"blocked_extensions" yaml:"blocked_extensions" toml:"blocked_extensions"`
+	TrapMaxPathDepth   int        `json:"trap_max_path_depth" yaml:"trap_max_path_depth" toml:"trap_max_path_depth"`
+	TrapMaxPathLength  int        `json:"trap_max_path_length" yaml:"trap_max_path_length" toml:"trap_max_path_length"`
+	TrapMaxRepeats     int        `json:"trap_max_repeats" yaml:"trap_max_repeats" toml:"trap_max_repeats"`
+	TrapMaxPerTemplate int        `json:"trap_max_per_template" yaml:"trap_max_per_template" toml:"trap_max_per_template"`
+	SessionParams      stringList `json:"session_params" yaml:"session_params" toml:"session_params"`
+	MetricsAddr        string     `json:"metrics_addr" yaml:"metrics_addr" toml:"metrics_addr"`
+	NearDupDistance    int        `json:"near_dup_distance" yaml:"near_dup_distance" toml:"near_dup_distance"`
+	NearDupSimilarity  float64    `json:"near_dup_similarity" yaml:"near_dup_similarity" toml:"near_dup_similarity"`
+	RetryAttempts      int        `json:"retry_attempts" yaml:"retry_attempts" toml:"retry_attempts"`
+	RetryBaseDelay     duration   `json:"retry_base_delay" yaml:"retry_base_delay" toml:"retry_base_delay"`
+	RetryMaxDelay      duration   `json:"retry_max_delay" yaml:"retry_max_delay" toml:"retry_max_delay"`
+	BreakerThreshold   int        `json:"breaker_threshold" yaml:"breaker_threshold" toml:"breaker_threshold"`
+	BreakerCooldown    duration   `json:"breaker_cooldown" yaml:"breaker_cooldown" toml:"breaker_cooldown"`
+}
+
+func defaultConfig() config {
+	return config{
+		SeedsPath:          "crawler/seeds.txt",
+		OutputDir:          "corpus",
+		Reset:              false,
+		Workers:            512,
+		FetchTimeout:       duration(5 * time.Second),
+		DownloadTimeout:    duration(30 * time.Second),
+		MaxBodySize:        10 << 20,
+		RobotsTimeout:      duration(5 * time.Second),
+		UserAgent:          "go-search-bot/0.0.1",
+		From:               "",
+		MaxConnsPerHost:    4,
+		IdleConnsPerHost:   2,
+		IdleConnTimeout:    duration(90 * time.Second),
+		MaxConnsPerIP:      0,
+		DNSServers:         nil,
+		DNSTimeout:         duration(2 * time.Second),
+		DNSCacheSize:       1 << 16,
+		DNSMinTTL:          duration(time.Minute),
+		DNSMaxTTL:          duration(6 * time.Hour),
+		DNSNegativeTTL:     duration(5 * time.Minute),
+		RobotsCacheSize:    1024,
+		RobotsErrorTTL:     duration(10 * time.Minute),
+		WarcPrefix:         "go-search",
+		WarcMaxSize:        1 << 30,
+		MaxPages:           0,
+		MaxBytes:           0,
+		MaxDuration:        0,
+		MaxDepth:           0,
+		MaxRedirects:       5,
+		CheckpointDir:      "",
+		CheckpointInterval: duration(time.Minute),
+		Resume:             false,
+		Recrawl:            false,
+		RecrawlBudget:      0,
+		RecrawlMinInterval: duration(time.Hour),
+		RecrawlMaxInterval: duration(30 * 24 * time.Hour),
+		RecrawlFirstVisit:  duration(24 * time.Hour),
+		Scope:              string(scope.ModeAny),
+		ScopeInclude:       nil,
+		ScopeExclude:       nil,
+		BlockedExtensions: stringList{
+			"7z", "avi", "bmp", "css", "dmg", "doc", "docx", "eot", "exe", "gif", "gz", "ico", "iso",
+			"jpeg", "jpg", "js", "mov", "mp3", "mp4", "ogg", "otf", "pdf", "png", "ppt", "pptx", "rar",
+			"svg", "tar", "tif", "tiff", "ttf", "wav", "webm", "webp", "woff", "woff2", "xls", "xlsx", "zip",
+		},
+		TrapMaxPathDepth:   16,
+		TrapMaxPathLength:  1024,
+		TrapMaxRepeats:     3,
+		TrapMaxPerTemplate: 2000,
+		SessionParams:      stringList(traps.DefaultSessionParams),
+		MetricsAddr:        "",
+		NearDupDistance:    3,
+		NearDupSimilarity:  0.8,
+		RetryAttempts:      3,
+		RetryBaseDelay:     duration(time.Second),
+		RetryMaxDelay:      duration(5 * time.Minute),
+		BreakerThreshold:   5,
+		BreakerCooldown:    duration(5 * time.Minute),
+	}
+}
+
+func (self *config) bind(fs *flag.FlagSet) {
+	fs.StringVar(&self.SeedsPath, "seeds", self.SeedsPath, "file with one seed URL per line")
+	fs.StringVar(&self.OutputDir, "output-dir", self.OutputDir, "directory the WARC files are written to")
+	fs.BoolVar(&self.Reset, "reset", self.Reset, "delete the output directory before crawling")
+	fs.IntVar(&self.Workers, "workers", self.Workers, "number of concurrent fetches")
+	fs.Var(&self.FetchTimeout, "fetch-timeout", "time to wait for a page's response headers")
+	fs.Var(&self.DownloadTimeout, "download-timeout", "time allowed for downloading a body before it is truncated")
+	fs.Int64Var(&self.MaxBodySize, "max-body-size", self.MaxBodySize, "bytes of a body that are kept before it is truncated")
+	fs.Var(&self.RobotsTimeout, "robots-timeout", "timeout for a robots.txt fetch")
+	fs.StringVar(&self.UserAgent, "user-agent", self.UserAgent, "User-Agent header sent with every request")
+	fs.StringVar(&self.From, "from", self.From, "contact address sent as the From header (omitted when empty)")
+	fs.IntVar(&self.MaxConnsPerHost, "max-conns-per-host", self.MaxConnsPerHost, "open connections allowed to one host (0 for no limit)")
+	fs.IntVar(&self.IdleConnsPerHost, "idle-conns-per-host", self.IdleConnsPerHost, "keep-alive connections kept open per host")
+	fs.Var(&self.IdleConnTimeout, "idle-conn-timeout", "how long an unused keep-alive connection is kept open")
+	fs.IntVar(&self.MaxConnsPerIP, "max-conns-per-ip", self.MaxConnsPerIP, "concurrent fetches allowed to one IP address across hosts (0 for no limit)")
+	fs.Var(&self.DNSServers, "dns-servers", "comma-separated ip:port DNS servers (default from /etc/resolv.conf)")
+	fs.Var(&self.DNSTimeout, "dns-timeout", "timeout for one DNS query")
+	fs.UintVar(&self.DNSCacheSize, "dns-cache-size", self.DNSCacheSize, "number of host names kept in the DNS cache")
+	fs.Var(&self.DNSMinTTL, "dns-min-ttl", "shortest time a DNS answer is cached, whatever its TTL")
+	fs.Var(&self.DNSMaxTTL, "dns-max-ttl", "longest time a DNS answer is cached (0 for no limit)")
+	fs.Var(&self.DNSNegativeTTL, "dns-negative-ttl", "longest time a host that does not exist is remembered")
+	fs.UintVar(&self.RobotsCacheSize, "robots-cache-size", self.RobotsCacheSize, "number of robots.txt files kept in memory")
+	fs.Var(&self.RobotsErrorTTL, "robots-error-ttl", "how long a host whose robots.txt could not be fetched is treated as disallowed")
+	fs.StringVar(&self.WarcPrefix, "warc-prefix", self.WarcPrefix, "prefix of the WARC file names")
+	fs.Int64Var(&self.WarcMaxSize, "warc-max-size", self.WarcMaxSize, "size in bytes at which WARC files roll over")
+	fs.Int64Var(&self.MaxPages, "max-pages", self.MaxPages, "stop after archiving this many pages (0 for no limit)")
+	fs.Int64Var(&self.MaxBytes, "max-bytes", self.MaxBytes, "stop after writing this many WARC bytes (0 for no limit)")
+	fs.Var(&self.MaxDuration, "max-duration", "stop after crawling for this long (0 for no limit)")
+	fs.IntVar(&self.MaxDepth, "max-depth", self.MaxDepth, "do not follow links more than this many hops from a seed (0 for no limit)")
+	fs.IntVar(&self.MaxRedirects, "max-redirects", self.MaxRedirects, "redirects followed in one chain before giving up")
+	fs.StringVar(&self.CheckpointDir, "checkpoint-dir", self.CheckpointDir, "directory crawl state is saved to (default <output-dir>/checkpoint)")
+	fs.Var(&self.CheckpointInterval, "checkpoint-interval", "how often crawl state is saved (0 to save only on exit)")
+	fs.BoolVar(&self.Resume, "resume", self.Resume, "continue the crawl saved in the checkpoint directory")
+	fs.BoolVar(&self.Recrawl, "recrawl", self.Recrawl, "crawl again the pages archived by the crawl saved in the checkpoint directory, fetching them conditionally")
+	fs.Float64Var(&self.RecrawlBudget, "recrawl-budget", self.RecrawlBudget, "revisits per hour shared by archived pages by their change rate; keeps the crawl running (0 to crawl once)")
+	fs.Var(&self.RecrawlMinInterval, "recrawl-min-interval", "shortest time between two visits of a page")
+	fs.Var(&self.RecrawlMaxInterval, "recrawl-max-interval", "longest time a page goes without a visit")
+	fs.Var(&self.RecrawlFirstVisit, "recrawl-first-visit", "time before the first revisit of a page, while its change rate is unknown")
+	fs.StringVar(&self.Scope, "scope", self.Scope, "default seed scope: any, host, domain or prefix")
+	fs.Var(&self.ScopeInclude, "scope-include", "comma-separated regexes or surt: prefixes always in scope")
+	fs.Var(&self.ScopeExclude, "scope-exclude", "comma-separated regexes or surt: prefixes never in scope")
+	fs.Var(&self.BlockedExtensions, "blocked-extensions", "comma-separated file extensions that are never fetched")
+	fs.IntVar(&self.TrapMaxPathDepth, "trap-max-path-depth", self.TrapMaxPathDepth, "path segments beyond which a URL is treated as a trap (0 for no limit)")
+	fs.IntVar(&self.TrapMaxPathLength, "trap-max-path-length", self.TrapMaxPathLength, "path length in bytes beyond which a URL is treated as a trap (0 for no limit)")
+	fs.IntVar(&self.TrapMaxRepeats, "trap-max-repeats", self.TrapMaxRepeats, "times one path segment may repeat, as in /a/a/a/ (0 for no limit)")
+	fs.IntVar(&self.TrapMaxPerTemplate, "trap-max-per-template", self.TrapMaxPerTemplate, "URLs per host sharing a path template such as /events/{n}/{n} (0 for no limit)")
+	fs.Var(&self.SessionParams, "session-params", "comma-separated session-ID parameter names stripped from URLs")
+	fs.StringVar(&self.MetricsAddr, "metrics-addr", self.MetricsAddr, "address serving crawl metrics at /debug/vars (empty to disable)")
+	fs.IntVar(&self.NearDupDistance, "near-dup-distance", self.NearDupDistance, "SimHash bits two near-duplicate pages may differ in")
+	fs.Float64Var(&self.NearDupSimilarity, "near-dup-similarity", self.NearDupSimilarity, "MinHash similarity from which two pages are near-duplicates")
+	fs.IntVar(&self.RetryAttempts, "retry-attempts", self.RetryAttempts, "attempts per URL for DNS errors, timeouts, 429 and 5xx")
+	fs.Var(&self.RetryBaseDelay, "retry-base-delay", "delay before the first retry, doubled for each further attempt")
+	fs.Var(&self.RetryMaxDelay, "retry-max-delay", "upper bound on the retry backoff; a longer Retry-After gives up on the URL")
+	fs.IntVar(&self.BreakerThreshold, "breaker-threshold", self.BreakerThreshold, "consecutive failures after which a host is paused")
+	fs.Var(&self.BreakerCooldown, "breaker-cooldown", "how long a failing host is paused before it is probed again")
+}
+
+func loadConfig(args []string) (config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "JSON, YAML or TOML configuration file")
+	cfg.bind(fs)
+
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		fs.SetOutput(os.Stderr)
+		fs.Usage()
+		return cfg, err
+	}
+	fs.SetOutput(os.Stderr)
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return cfg, err
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok {
+			if err := f.Value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return cfg, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if cfg.CheckpointDir == "" {
+		cfg.CheckpointDir = filepath.Join(cfg.OutputDir, "checkpoint")
+	}
+
+	return cfg, cfg.validate()
+}
+
+func (self *config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config error: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, self)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, self)
+	case ".toml":
+		err = toml.Unmarshal(data, self)
+	default:
+		return fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse config error: %w", err)
+	}
+
+	return nil
+}
+
+func (self *config) validate() error {
+	var errs []error
+
+	if self.SeedsPath == "" {
+		errs = append(errs, errors.New("seeds must be set"))
+	} else if _, err := os.Stat(self.SeedsPath); err != nil {
+		errs = append(errs, fmt.Errorf("seeds: %w", err))
+	}
+	if self.OutputDir == "" {
+		errs = append(errs, errors.New("output_dir must be set"))
+	}
+	if self.Workers <= 0 {
+		errs = append(errs, fmt.Errorf("workers must be positive, got %d", self.Workers))
+	}
+	if self.FetchTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("fetch_timeout must be positive, got %s", self.FetchTimeout))
+	}
+	if self.DownloadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("download_timeout must be positive, got %s", self.DownloadTimeout))
+	}
+	if self.MaxBodySize <= 0 {
+		errs = append(errs, fmt.Errorf("max_body_size must be positive, got %d", self.MaxBodySize))
+	}
+	if self.RobotsTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("robots_timeout must be positive, got %s", self.RobotsTimeout))
+	}
+	if strings.TrimSpace(self.UserAgent) == "" {
+		errs = append(errs, errors.New("user_agent must be set"))
+	}
+	if self.MaxConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("max_conns_per_host must not be negative, got %d", self.MaxConnsPerHost))
+	}
+	if self.IdleConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("idle_conns_per_host must not be negative, got %d", self.IdleConnsPerHost))
+	}
+	if self.IdleConnTimeout < 0 {
+		errs = append(errs, fmt.Errorf("idle_conn_timeout must not be negative, got %s", self.IdleConnTimeout))
+	}
+	if self.MaxConnsPerIP < 0 {
+		errs = append(errs, fmt.Errorf("max_conns_per_ip must not be negative, got %d", self.MaxConnsPerIP))
+	}
+	if self.DNSTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("dns_timeout must be positive, got %s", self.DNSTimeout))
+	}
+	if self.DNSCacheSize == 0 {
+		errs = append(errs, errors.New("dns_cache_size must be positive"))
+	}
+	if self.DNSMinTTL < 0 || self.DNSMaxTTL < 0 || self.DNSNegativeTTL < 0 {
+		errs = append(errs, errors.New("dns_min_ttl, dns_max_ttl and dns_negative_ttl must not be negative"))
+	} else if self.DNSMaxTTL > 0 && self.DNSMaxTTL < self.DNSMinTTL {
+		errs = append(errs, fmt.Errorf("dns_max_ttl %s is below dns_min_ttl %s", self.DNSMaxTTL, self.DNSMinTTL))
+	}
+	if self.RobotsCacheSize == 0 {
+		errs = append(errs, errors.New("robots_cache_size must be positive"))
+	}
+	if self.RobotsErrorTTL <= 0 {
+		errs = append(errs, fmt.Errorf("robots_error_ttl must be positive, got %s", self.RobotsErrorTTL))
+	}
+	if self.WarcPrefix == "" {
+		errs = append(errs, errors.New("warc_prefix must be set"))
+	}
+	if self.WarcMaxSize <= 0 {
+		errs = append(errs, fmt.Errorf("warc_max_size must be positive, got %d", self.WarcMaxSize))
+	}
+	if self.MaxPages < 0 {
+		errs = append(errs, fmt.Errorf("max_pages must not be negative, got %d", self.MaxPages))
+	}
+	if self.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_bytes must not be negative, got %d", self.MaxBytes))
+	}
+	if self.MaxDuration < 0 {
+		errs = append(errs, fmt.Errorf("max_duration must not be negative, got %s", self.MaxDuration))
+	}
+	if self.MaxDepth < 0 {
+		errs = append(errs, fmt.Errorf("max_depth must not be negative, got %d", self.MaxDepth))
+	}
+	if self.MaxRedirects < 0 {
+		errs = append(errs, fmt.Errorf("max_redirects must not be negative, got %d", self.MaxRedirects))
+	}
+	if self.Recrawl && self.Reset {
+		errs = append(errs, errors.New("recrawl needs the previous crawl's state and cannot be combined with reset"))
+	}
+	if self.RecrawlBudget < 0 {
+		errs = append(errs, fmt.Errorf("recrawl_budget must not be negative, got %v", self.RecrawlBudget))
+	}
+	if self.RecrawlMinInterval <= 0 || self.RecrawlMaxInterval < self.RecrawlMinInterval {
+		errs = append(errs, fmt.Errorf("recrawl intervals must satisfy 0 < recrawl_min_interval <= recrawl_max_interval, got %s and %s", self.RecrawlMinInterval, self.RecrawlMaxInterval))
+	}
+	if self.RecrawlFirstVisit <= 0 {
+		errs = append(errs, fmt.Errorf("recrawl_first_visit must be positive, got %s", self.RecrawlFirstVisit))
+	}
+	if self.CheckpointInterval < 0 {
+		errs = append(errs, fmt.Errorf("checkpoint_interval must not be negative, got %s", self.CheckpointInterval))
+	}
+	switch scope.Mode(self.Scope) {
+	case scope.ModeAny, scope.ModeHost, scope.ModeDomain, scope.ModePrefix:
+	default:
+		errs = append(errs, fmt.Errorf("scope must be any, host, domain or prefix, got %q", self.Scope))
+	}
+	if self.TrapMaxPathDepth < 0 || self.TrapMaxPathLength < 0 || self.TrapMaxRepeats < 0 || self.TrapMaxPerTemplate < 0 {
+		errs = append(errs, errors.New("trap limits must not be negative"))
+	}
+	if self.NearDupDistance < 0 || self.NearDupDistance > 16 {
+		errs = append(errs, errors.New("near-dup-distance must be between 0 and 16"))
+	}
+	if self.NearDupSimilarity <= 0 || self.NearDupSimilarity > 1 {
+		errs = append(errs, errors.New("near-dup-similarity must be in (0, 1]"))
+	}
+	if self.RetryAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("retry_attempts must be positive, got %d", self.RetryAttempts))
+	}
+	if self.RetryBaseDelay <= 0 || self.RetryMaxDelay < self.RetryBaseDelay {
+		errs = append(errs, fmt.Errorf("retry delays must satisfy 0 < retry_base_delay <= retry_max_delay, got %s and %s", self.RetryBaseDelay, self.RetryMaxDelay))
+	}
+	if self.BreakerThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("breaker_threshold must be positive, got %d", self.BreakerThreshold))
+	}
+	if self.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("breaker_cooldown must be positive, got %s", self.BreakerCooldown))
+	}
+	if self.Resume && self.Reset {
+		errs = append(errs, errors.New("resume and reset cannot be used together"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
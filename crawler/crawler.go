@@ -0,0 +1,1238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anaskhan96/soup"
+
+	"github.com/guilherme13c/go-search/utils/charset"
+	"github.com/guilherme13c/go-search/utils/dedup"
+	"github.com/guilherme13c/go-search/utils/extract"
+	"github.com/guilherme13c/go-search/utils/fetcher"
+	"github.com/guilherme13c/go-search/utils/langid"
+	"github.com/guilherme13c/go-search/utils/links"
+	lrucache "github.com/guilherme13c/go-search/utils/lru-cache"
+	"github.com/guilherme13c/go-search/utils/queue"
+	"github.com/guilherme13c/go-search/utils/recrawl"
+	"github.com/guilherme13c/go-search/utils/resolver"
+	"github.com/guilherme13c/go-search/utils/retry"
+	robotsmeta "github.com/guilherme13c/go-search/utils/robots-meta"
+	robotstxt "github.com/guilherme13c/go-search/utils/robots-txt"
+	"github.com/guilherme13c/go-search/utils/scope"
+	"github.com/guilherme13c/go-search/utils/set"
+	"github.com/guilherme13c/go-search/utils/traps"
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+var (
+	errFrontierExhausted = errors.New("frontier exhausted")
+	errMaxPages          = errors.New("page limit reached")
+	errMaxBytes          = errors.New("byte limit reached")
+	errMaxDuration       = errors.New("time limit reached")
+	errInterrupted       = errors.New("interrupted")
+
+	errHeaderTimeout   = fmt.Errorf("response header timeout: %w", context.DeadlineExceeded)
+	errDownloadTimeout = fmt.Errorf("download timeout: %w", context.DeadlineExceeded)
+)
+
+var (
+	linkExtractor    = links.NewExtractor(links.Options{})
+	contentExtractor = extract.NewExtractor(extract.Options{})
+)
+
+type frontierItem struct {
+	URL       string
+	Via       string
+	Seed      string
+	Depth     int
+	Attempt   int
+	NotBefore time.Time
+	// Redirects holds the chain of URLs that redirected to this one from
+	// other hosts, so the hop limit and loop check carry across the
+	// frontier.
+	Redirects []string
+}
+
+type robotsFailure struct {
+	until time.Time
+	err   error
+}
+
+type crawler struct {
+	cfg          config
+	scope        scope.Scope
+	traps        traps.Detector
+	frontier     queue.Queue[frontierItem]
+	visited      set.Set[string]
+	canonicals   *canonicalIndex
+	captures     *captureIndex
+	digests      *digestIndex
+	nearDups     dedup.Index
+	languages    langid.Identifier
+	history      *historyIndex
+	scheduler    recrawl.Scheduler
+	robotsCache  lrucache.LruCache[string, robotstxt.Robotstxt]
+	robotsMu     sync.Mutex
+	robotsWait   map[string]chan struct{}
+	robotsFailed map[string]robotsFailure
+	robotsParser *robotstxt.Parser
+	fetcher      fetcher.Fetcher
+	resolver     resolver.Resolver
+	ipSlots      *ipSlots
+	warcFiles    warc.FileManager
+	retryPolicy  retry.Policy
+	breaker      retry.Breaker
+
+	semaphore chan struct{}
+	finished  chan struct{}
+	wg        sync.WaitGroup
+	inFlight  atomic.Int64
+	pages     atomic.Int64
+	bytes     atomic.Int64
+
+	stateMu sync.Mutex
+	active  map[string]frontierItem
+	delayed map[string]frontierItem
+	due     []frontierItem
+}
+
+func newCrawler(cfg config, warcFiles warc.FileManager) (*crawler, error) {
+	crawlScope, err := scope.NewScope(scope.Options{
+		Mode:              scope.Mode(cfg.Scope),
+		MaxHops:           cfg.MaxDepth,
+		Include:           cfg.ScopeInclude,
+		Exclude:           cfg.ScopeExclude,
+		BlockedExtensions: cfg.BlockedExtensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dns, err := resolver.NewResolver(resolver.Options{
+		Servers:     cfg.DNSServers,
+		Timeout:     time.Duration(cfg.DNSTimeout),
+		DialTimeout: time.Duration(cfg.FetchTimeout),
+		CacheSize:   cfg.DNSCacheSize,
+		MinTTL:      time.Duration(cfg.DNSMinTTL),
+		MaxTTL:      time.Duration(cfg.DNSMaxTTL),
+		NegativeTTL: time.Duration(cfg.DNSNegativeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	languages, err := langid.NewIdentifier(langid.Options{})
+	if err != nil {
+		return nil, err
+	}
+	var slots *ipSlots
+	if cfg.MaxConnsPerIP > 0 {
+		slots = newIPSlots(cfg.MaxConnsPerIP)
+	}
+
+	pageFetcher := fetcher.NewFetcher(fetcher.Options{
+		UserAgent:           cfg.UserAgent,
+		From:                cfg.From,
+		MaxIdleConns:        cfg.Workers,
+		MaxIdleConnsPerHost: cfg.IdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.IdleConnTimeout),
+		DialTimeout:         time.Duration(cfg.FetchTimeout),
+		TLSHandshakeTimeout: time.Duration(cfg.FetchTimeout),
+		DialContext:         dns.DialContext,
+	})
+
+	revisits := recrawl.NewScheduler(recrawl.Options{
+		Budget:          cfg.RecrawlBudget / 3600,
+		MinInterval:     time.Duration(cfg.RecrawlMinInterval),
+		MaxInterval:     time.Duration(cfg.RecrawlMaxInterval),
+		InitialInterval: time.Duration(cfg.RecrawlFirstVisit),
+	})
+
+	return &crawler{
+		cfg:   cfg,
+		scope: crawlScope,
+		traps: traps.NewDetector(traps.Options{
+			MaxPathDepth:   cfg.TrapMaxPathDepth,
+			MaxPathLength:  cfg.TrapMaxPathLength,
+			MaxRepeats:     cfg.TrapMaxRepeats,
+			MaxPerTemplate: cfg.TrapMaxPerTemplate,
+			SessionParams:  cfg.SessionParams,
+		}),
+		frontier:   queue.NewQueue[frontierItem](0),
+		visited:    set.NewSet[string](),
+		canonicals: newCanonicalIndex(),
+		captures:   newCaptureIndex(),
+		digests:    newDigestIndex(),
+		nearDups: dedup.NewIndex(dedup.Options{
+			MaxDistance:   cfg.NearDupDistance,
+			MinSimilarity: cfg.NearDupSimilarity,
+		}),
+		history:      newHistoryIndex(),
+		scheduler:    revisits,
+		languages:    languages,
+		robotsCache:  lrucache.NewLruCache[string, robotstxt.Robotstxt](cfg.RobotsCacheSize),
+		robotsMu:     sync.Mutex{},
+		robotsWait:   make(map[string]chan struct{}),
+		robotsFailed: make(map[string]robotsFailure),
+		robotsParser: robotstxt.NewParser(pageFetcher.Client(time.Duration(cfg.RobotsTimeout))),
+		fetcher:      pageFetcher,
+		resolver:     dns,
+		ipSlots:      slots,
+		warcFiles:    warcFiles,
+		retryPolicy: retry.Policy{
+			MaxAttempts: cfg.RetryAttempts,
+			BaseDelay:   time.Duration(cfg.RetryBaseDelay),
+			MaxDelay:    time.Duration(cfg.RetryMaxDelay),
+		},
+		breaker:   retry.NewBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown)),
+		semaphore: make(chan struct{}, cfg.Workers),
+		finished:  make(chan struct{}, 1),
+		stateMu:   sync.Mutex{},
+		active:    make(map[string]frontierItem),
+		delayed:   make(map[string]frontierItem),
+	}, nil
+}
+
+func (self *crawler) AddSeed(seed scope.Seed) error {
+	return self.scope.AddSeed(seed)
+}
+
+// Enqueue marks a URL as visited and queues it under one hold of stateMu, so
+// a checkpoint never sees it in one and not the other.
+func (self *crawler) Enqueue(item frontierItem) bool {
+	item.URL = self.traps.Normalize(item.URL)
+
+	self.stateMu.Lock()
+	defer self.stateMu.Unlock()
+
+	if !self.admit(item) {
+		return false
+	}
+	self.frontier.Put(item)
+
+	return true
+}
+
+// admit marks a normalized URL as visited if it is new, in scope and not a
+// trap. stateMu must be held.
+func (self *crawler) admit(item frontierItem) bool {
+	if self.visited.Contains(item.URL) {
+		return false
+	}
+	if err := self.scope.Check(item.URL, item.Seed, item.Depth); err != nil {
+		fmt.Fprintf(os.Stderr, "rejected %v (via %s)\n", err, item.Via)
+		return false
+	}
+	if err := self.traps.Check(item.URL); err != nil {
+		fmt.Fprintf(os.Stderr, "rejected %v (via %s)\n", err, item.Via)
+		return false
+	}
+	self.visited.Add(item.URL)
+
+	return true
+}
+
+func (self *crawler) schedule(item frontierItem, at time.Time) {
+	item.NotBefore = at
+
+	self.stateMu.Lock()
+	self.delayed[item.URL] = item
+	self.stateMu.Unlock()
+
+	time.AfterFunc(time.Until(at), func() {
+		self.stateMu.Lock()
+		delete(self.delayed, item.URL)
+		self.frontier.Put(item)
+		self.stateMu.Unlock()
+
+		self.notify()
+	})
+}
+
+func (self *crawler) retryLater(item frontierItem, host string, retryAfter time.Duration, cause error) {
+	now := time.Now()
+	if self.breaker.Failure(host, now) {
+		fmt.Fprintf(os.Stderr, "circuit open for %s: %v\n", host, cause)
+	}
+
+	if !self.retryPolicy.ShouldRetry(item.Attempt + 1) {
+		fmt.Fprintf(os.Stderr, "giving up on %s after %d attempts: %v\n", item.URL, item.Attempt+1, cause)
+		return
+	}
+	delay, ok := self.retryPolicy.Delay(item.Attempt+1, retryAfter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "giving up on %s: retry after %v exceeds the retry budget: %v\n", item.URL, retryAfter, cause)
+		return
+	}
+	item.Attempt++
+	self.schedule(item, now.Add(delay))
+}
+
+func (self *crawler) notify() {
+	select {
+	case self.finished <- struct{}{}:
+	default:
+	}
+}
+
+func (self *crawler) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	if self.cfg.CheckpointInterval > 0 {
+		go self.checkpointEvery(time.Duration(self.cfg.CheckpointInterval), done)
+	}
+	if self.continuous() {
+		tick := min(max(time.Duration(self.cfg.RecrawlMinInterval)/4, time.Second), time.Minute)
+		go self.revisitEvery(tick, done)
+	}
+
+	reason := self.dispatch(ctx)
+	self.wg.Wait()
+
+	return reason
+}
+
+func (self *crawler) checkpointEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := self.SaveCheckpoint("periodic"); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+}
+
+func (self *crawler) continuous() bool {
+	return self.cfg.RecrawlBudget > 0
+}
+
+// revisitEvery queues the pages the scheduler finds due, most likely stale
+// first, ahead of the frontier. They bypass the visited set, which only guards
+// against discovering a URL twice.
+func (self *crawler) revisitEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			self.scheduler.Plan()
+			due := self.scheduler.Due(now)
+			if len(due) == 0 {
+				continue
+			}
+
+			self.stateMu.Lock()
+			for _, targetURI := range due {
+				state, ok := self.history.Get(targetURI)
+				if !ok {
+					continue
+				}
+				self.visited.Add(targetURI)
+				self.due = append(self.due, frontierItem{URL: targetURI, Seed: state.Seed, Depth: state.Depth})
+			}
+			self.stateMu.Unlock()
+			self.notify()
+		}
+	}
+}
+
+func (self *crawler) dispatch(ctx context.Context) error {
+	for {
+		if err := self.limitReached(); err != nil {
+			return err
+		}
+		if self.cfg.MaxPages > 0 && self.pages.Load()+self.inFlight.Load() >= self.cfg.MaxPages {
+			if err := self.waitForWorker(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return stopReason(ctx)
+		case self.semaphore <- struct{}{}:
+		}
+
+		self.stateMu.Lock()
+		idle := self.inFlight.Load() == 0 && len(self.delayed) == 0 &&
+			!(self.continuous() && self.scheduler.Len() > 0)
+		item, ok := self.next()
+		if ok {
+			self.active[item.URL] = item
+		}
+		self.stateMu.Unlock()
+		if !ok {
+			<-self.semaphore
+			if idle {
+				return errFrontierExhausted
+			}
+			if err := self.waitForWorker(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		self.inFlight.Add(1)
+		self.wg.Add(1)
+		go func() {
+			defer func() {
+				self.stateMu.Lock()
+				delete(self.active, item.URL)
+				self.stateMu.Unlock()
+
+				<-self.semaphore
+				self.inFlight.Add(-1)
+				self.wg.Done()
+				self.notify()
+			}()
+			self.visit(item)
+		}()
+	}
+}
+
+// next takes due revisits in the scheduler's order before anything on the
+// frontier. stateMu must be held.
+func (self *crawler) next() (frontierItem, bool) {
+	if len(self.due) > 0 {
+		item := self.due[0]
+		self.due = slices.Delete(self.due, 0, 1)
+		return item, true
+	}
+
+	return self.frontier.Get()
+}
+
+func (self *crawler) waitForWorker(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return stopReason(ctx)
+	case <-self.finished:
+		return nil
+	}
+}
+
+func (self *crawler) limitReached() error {
+	if self.cfg.MaxPages > 0 && self.pages.Load() >= self.cfg.MaxPages {
+		return errMaxPages
+	}
+	if self.cfg.MaxBytes > 0 && self.bytes.Load() >= self.cfg.MaxBytes {
+		return errMaxBytes
+	}
+
+	return nil
+}
+
+func stopReason(ctx context.Context) error {
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+
+	return errInterrupted
+}
+
+// The visited set and the frontier are taken under one hold of stateMu, so
+// every URL marked as visited is either finished, queued or in flight in the
+// snapshot; in-flight items go back on the frontier when the checkpoint is
+// restored. An item waiting to be retried is still in flight until its worker
+// returns, and is saved once, with its retry time.
+func (self *crawler) Checkpoint(reason string) (checkpoint, error) {
+	self.stateMu.Lock()
+	visited, err := self.visited.MarshalBinary()
+	if err != nil {
+		self.stateMu.Unlock()
+		return checkpoint{}, err
+	}
+	frontier := []frontierItem{}
+	saved := make(map[string]struct{})
+	for _, items := range [][]frontierItem{self.due, self.frontier.Items(), slices.Collect(maps.Values(self.delayed)), slices.Collect(maps.Values(self.active))} {
+		for _, item := range items {
+			if _, ok := saved[item.URL]; ok {
+				continue
+			}
+			saved[item.URL] = struct{}{}
+			frontier = append(frontier, item)
+		}
+	}
+	self.stateMu.Unlock()
+
+	robots := []robotsEntry{}
+	self.robotsMu.Lock()
+	for domain, rules := range self.robotsCache.All() {
+		robots = append(robots, robotsEntry{Domain: domain, Rules: rules})
+	}
+	self.robotsMu.Unlock()
+
+	canonicals, clusters, err := self.canonicals.Snapshot()
+	if err != nil {
+		return checkpoint{}, err
+	}
+
+	return checkpoint{
+		Time:           time.Now().UTC(),
+		Reason:         reason,
+		Pages:          self.pages.Load(),
+		Bytes:          self.bytes.Load(),
+		WarcSerial:     self.warcFiles.Serial(),
+		Frontier:       frontier,
+		Visited:        visited,
+		Robots:         robots,
+		Captures:       self.captures.Snapshot(),
+		Canonicals:     canonicals,
+		Clusters:       clusters,
+		Duplicates:     self.digests.Snapshot(),
+		NearDuplicates: self.nearDups.Snapshot(),
+		NearDupGroups:  self.nearDups.Groups(),
+		History:        self.history.Snapshot(),
+		Schedule:       self.scheduler.Snapshot(),
+		Traps:          self.traps.Snapshot(),
+		Breaker:        self.breaker.Snapshot(),
+	}, nil
+}
+
+func (self *crawler) SaveCheckpoint(reason string) error {
+	cp, err := self.Checkpoint(reason)
+	if err != nil {
+		return fmt.Errorf("checkpoint error: %w", err)
+	}
+
+	return writeCheckpoint(self.cfg.CheckpointDir, cp)
+}
+
+func (self *crawler) Restore(cp checkpoint) error {
+	if err := self.visited.UnmarshalBinary(cp.Visited); err != nil {
+		return fmt.Errorf("restore visited error: %w", err)
+	}
+	for _, item := range cp.Frontier {
+		self.visited.Add(item.URL)
+		if item.NotBefore.After(time.Now()) {
+			self.schedule(item, item.NotBefore)
+			continue
+		}
+		self.frontier.Put(item)
+	}
+
+	for i := len(cp.Robots) - 1; i >= 0; i-- {
+		self.robotsCache.Put(cp.Robots[i].Domain, cp.Robots[i].Rules)
+	}
+
+	self.captures.Restore(cp.Captures)
+	self.digests.Restore(cp.Captures, cp.Duplicates)
+	self.nearDups.Restore(cp.NearDuplicates)
+	self.history.Restore(cp.History)
+	self.scheduler.Restore(cp.Schedule)
+	self.traps.Restore(cp.Traps)
+	self.breaker.Restore(cp.Breaker)
+	if err := self.canonicals.Restore(cp.Canonicals, cp.Clusters); err != nil {
+		return fmt.Errorf("restore canonicals error: %w", err)
+	}
+
+	self.pages.Store(cp.Pages)
+	self.bytes.Store(cp.Bytes)
+
+	return nil
+}
+
+// LoadHistory starts a recrawl from a finished crawl's checkpoint: the pages it
+// archived are queued again and their earlier captures are kept so unchanged
+// pages can be written as revisits. A continuous crawl leaves the queueing to
+// the scheduler, which knows which pages are due.
+func (self *crawler) LoadHistory(cp checkpoint) int {
+	self.captures.Restore(cp.Captures)
+	self.digests.Restore(cp.Captures, cp.Duplicates)
+	self.nearDups.Restore(cp.NearDuplicates)
+	self.history.Restore(cp.History)
+	self.scheduler.Restore(cp.Schedule)
+	if self.continuous() {
+		return 0
+	}
+
+	queued := 0
+	for _, targetURI := range slices.Sorted(maps.Keys(cp.History)) {
+		state := cp.History[targetURI]
+		if self.Enqueue(frontierItem{URL: targetURI, Seed: state.Seed, Depth: state.Depth}) {
+			queued++
+		}
+	}
+
+	return queued
+}
+
+type fetchResult struct {
+	req       *http.Request
+	resp      *http.Response
+	rawReq    []byte
+	rawResp   []byte
+	body      warc.Spool
+	digest    string
+	truncated string
+	start     time.Time
+	duration  time.Duration
+	ip        string
+}
+
+func (self *crawler) visit(item frontierItem) {
+	pageUrl := item.URL
+
+	domain, err := getDomain(pageUrl)
+	if err != nil {
+		return
+	}
+
+	if until, ok := self.breaker.Allow(domain, time.Now()); !ok {
+		self.schedule(item, until)
+		return
+	}
+
+	robotRules, until, err := self.robots(domain)
+	if err != nil {
+		// The host is held back by the crawler's own robots.txt cache
+		// rather than by the server, so the wait is no reason to give up.
+		wait := min(time.Until(until), time.Duration(self.cfg.RetryMaxDelay))
+		self.retryLater(item, domain, wait, fmt.Errorf("robots.txt unavailable: %w", err))
+		return
+	}
+	robotsTxt := *robotRules
+
+	u, err := url.Parse(pageUrl)
+	if err != nil {
+		return
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+
+	if !robotsTxt.Allowed(robotsmeta.ProductToken(self.cfg.UserAgent), u.RequestURI()) {
+		return
+	}
+
+	via := item.Via
+	chain := append(slices.Clone(item.Redirects), u.String())
+	followed := []string{}
+	defer func() {
+		self.stateMu.Lock()
+		for _, target := range followed {
+			delete(self.active, target)
+		}
+		self.stateMu.Unlock()
+	}()
+	for {
+		f, ok := self.fetch(item, domain, u)
+		if !ok {
+			return
+		}
+		next, ok := self.handle(item, via, u, f, &robotsTxt, &chain)
+		f.body.Close()
+		if !ok {
+			return
+		}
+		followed = append(followed, next.String())
+		via, u = u.String(), next
+	}
+}
+
+// handle archives a fetched response and returns the redirect target to fetch
+// next, if there is one to follow in this worker.
+func (self *crawler) handle(item frontierItem, via string, u *url.URL, f *fetchResult, robotsTxt *robotstxt.Robotstxt, chain *[]string) (*url.URL, bool) {
+	switch {
+	case f.resp.StatusCode == http.StatusNotModified:
+		self.notModified(via, u, f)
+		return nil, false
+	case !isRedirect(f.resp):
+		self.process(item, via, u, f)
+		return nil, false
+	}
+
+	return self.redirect(item, via, u, f, robotsTxt, chain)
+}
+
+// robots returns the cached rules for domain, fetching them when missing.
+// Workers that need a domain whose robots.txt is already being fetched wait for
+// that fetch instead of sending their own. Following RFC 9309, a robots.txt
+// answered with a 4xx allows everything and is cached like any other, while
+// one that is unreachable or answered with a 5xx disallows the whole host
+// until the returned time, after which it is fetched again.
+func (self *crawler) robots(domain string) (*robotstxt.Robotstxt, time.Time, error) {
+	self.robotsMu.Lock()
+	for {
+		if rules, ok := self.robotsCache.Get(domain); ok {
+			self.robotsMu.Unlock()
+			return rules, time.Time{}, nil
+		}
+		if failure, ok := self.robotsFailed[domain]; ok && time.Now().Before(failure.until) {
+			self.robotsMu.Unlock()
+			return nil, failure.until, failure.err
+		}
+		wait, pending := self.robotsWait[domain]
+		if !pending {
+			break
+		}
+		self.robotsMu.Unlock()
+		<-wait
+		self.robotsMu.Lock()
+	}
+	done := make(chan struct{})
+	self.robotsWait[domain] = done
+	self.robotsMu.Unlock()
+
+	rules, err := self.robotsParser.FetchAndParse(domain)
+	var statusErr *robotstxt.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+		rules, err = &robotstxt.Robotstxt{}, nil
+	}
+
+	var until time.Time
+	self.robotsMu.Lock()
+	if err == nil {
+		self.robotsCache.Put(domain, *rules)
+		delete(self.robotsFailed, domain)
+	} else {
+		until = time.Now().Add(time.Duration(self.cfg.RobotsErrorTTL))
+		self.robotsFailed[domain] = robotsFailure{until: until, err: err}
+	}
+	delete(self.robotsWait, domain)
+	self.robotsMu.Unlock()
+	close(done)
+
+	return rules, until, err
+}
+
+func (self *crawler) fetch(item frontierItem, domain string, u *url.URL) (*fetchResult, bool) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false
+	}
+
+	conditional := self.addValidators(req)
+
+	release, err := self.acquireIP(ctx, u.Hostname())
+	if err != nil {
+		if retry.Classify(nil, err) == retry.Transient {
+			self.retryLater(item, domain, 0, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "failed %s: %v\n", u, err)
+		}
+		return nil, false
+	}
+	defer release()
+
+	fetchStart := time.Now()
+	headerTimer := time.AfterFunc(time.Duration(self.cfg.FetchTimeout), func() { cancel(errHeaderTimeout) })
+	fetched, err := self.fetcher.Fetch(ctx, req)
+	headerTimer.Stop()
+	var resp *http.Response
+	if err == nil {
+		resp = fetched.Response
+	}
+	if err != nil && context.Cause(ctx) == errHeaderTimeout {
+		err = fmt.Errorf("%w: %v", errHeaderTimeout, err)
+	}
+	switch retry.Classify(resp, err) {
+	case retry.Transient:
+		var retryAfter time.Duration
+		if err == nil {
+			retryAfter, _ = retry.RetryAfter(resp.Header, time.Now())
+			resp.Body.Close()
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		self.retryLater(item, domain, retryAfter, err)
+		return nil, false
+	case retry.Permanent:
+		if err == nil {
+			resp.Body.Close()
+			self.breaker.Success(domain)
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		fmt.Fprintf(os.Stderr, "failed %s: %v\n", u, err)
+		return nil, false
+	}
+	defer resp.Body.Close()
+	self.breaker.Success(domain)
+
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		ip, _, _ := net.SplitHostPort(fetched.RemoteAddr)
+		return &fetchResult{
+			req:      resp.Request,
+			resp:     resp,
+			rawReq:   fetched.RawRequestHeader,
+			rawResp:  fetched.RawResponseHeader,
+			body:     warc.NewSpool(),
+			digest:   warc.Digest(nil),
+			start:    fetchStart,
+			duration: time.Since(fetchStart),
+			ip:       ip,
+		}, true
+	}
+	if !isRedirect(resp) {
+		if resp.StatusCode != http.StatusOK {
+			return nil, false
+		}
+		contentType := resp.Header.Get("Content-Type")
+		if !strings.Contains(contentType, "text/html") &&
+			!strings.Contains(contentType, "application/xhtml+xml") &&
+			!strings.Contains(contentType, "application/xml") {
+			return nil, false
+		}
+	}
+
+	downloadTimer := time.AfterFunc(time.Duration(self.cfg.DownloadTimeout), func() { cancel(errDownloadTimeout) })
+	defer downloadTimer.Stop()
+
+	body := warc.NewSpool()
+	hash := sha1.New()
+	truncated, err := readBody(ctx, io.MultiWriter(body, hash), resp.Body, self.cfg.MaxBodySize)
+	if err != nil {
+		body.Close()
+		if retry.Classify(nil, err) == retry.Transient {
+			self.retryLater(item, domain, 0, err)
+		}
+		return nil, false
+	}
+
+	ip, _, _ := net.SplitHostPort(fetched.RemoteAddr)
+
+	return &fetchResult{
+		req:       resp.Request,
+		resp:      resp,
+		rawReq:    fetched.RawRequestHeader,
+		rawResp:   fetched.RawResponseHeader,
+		body:      body,
+		digest:    warc.FormatDigest(hash.Sum(nil)),
+		truncated: truncated,
+		start:     fetchStart,
+		duration:  time.Since(fetchStart),
+		ip:        ip,
+	}, true
+}
+
+// readBody streams body to w, so it never has to fit in memory. Bodies over
+// limit are cut to limit bytes and bodies still downloading when the download
+// deadline cancels ctx keep what arrived; both are archived with the matching
+// WARC-Truncated reason instead of being dropped.
+func readBody(ctx context.Context, w io.Writer, body io.Reader, limit int64) (string, error) {
+	n, err := io.Copy(w, io.LimitReader(body, limit))
+	if err == nil && n == limit {
+		// Only a byte past the limit shows that the body was cut.
+		var probe [1]byte
+		if _, err = io.ReadFull(body, probe[:]); err == nil {
+			return warc.TruncatedLength, nil
+		}
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		if context.Cause(ctx) == errDownloadTimeout {
+			return warc.TruncatedTime, nil
+		}
+		return "", err
+	}
+
+	return "", nil
+}
+
+func isRedirect(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return resp.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// The 3xx is archived as its own capture. Its target is followed in the same
+// worker when it stays on the same scheme and host, so robots.txt rules
+// already in hand apply; otherwise it goes through the frontier carrying the
+// chain so far.
+func (self *crawler) redirect(item frontierItem, via string, from *url.URL, f *fetchResult, robotsTxt *robotstxt.Robotstxt, chain *[]string) (*url.URL, bool) {
+	target, err := from.Parse(f.resp.Header.Get("Location"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid redirect from %s: %v\n", from, err)
+		return nil, false
+	}
+	target.Fragment = ""
+	target.RawFragment = ""
+
+	response, err := newResponseRecord(from.String(), f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "archive %s: %v\n", from, err)
+		return nil, false
+	}
+	fields := captureFields(via, f)
+	fields.Add("location", target.String())
+	fields.Add("redirect-hop", strconv.Itoa(len(*chain)))
+	if _, err := self.store(from.String(), f, response, fields); err != nil {
+		return nil, false
+	}
+
+	next := frontierItem{URL: self.traps.Normalize(target.String()), Via: from.String(), Seed: item.Seed, Depth: item.Depth}
+	if slices.Contains(*chain, next.URL) {
+		fmt.Fprintf(os.Stderr, "redirect loop at %s -> %s\n", from, next.URL)
+		return nil, false
+	}
+	if len(*chain) > self.cfg.MaxRedirects {
+		fmt.Fprintf(os.Stderr, "too many redirects from %s\n", (*chain)[0])
+		return nil, false
+	}
+	*chain = append(*chain, next.URL)
+
+	if domain, _ := getDomain(next.URL); domain != fmt.Sprintf("%s://%s", from.Scheme, from.Host) {
+		next.Redirects = slices.Clone((*chain)[:len(*chain)-1])
+		self.Enqueue(next)
+		return nil, false
+	}
+	if target, err = url.Parse(next.URL); err != nil {
+		return nil, false
+	}
+	if !robotsTxt.Allowed(robotsmeta.ProductToken(self.cfg.UserAgent), target.RequestURI()) {
+		return nil, false
+	}
+
+	// The target is followed in this worker, so it counts as in flight until
+	// visit returns.
+	self.stateMu.Lock()
+	defer self.stateMu.Unlock()
+	if !self.admit(next) {
+		return nil, false
+	}
+	self.active[next.URL] = next
+
+	return target, true
+}
+
+func (self *crawler) process(item frontierItem, via string, u *url.URL, f *fetchResult) {
+	pageUrl := u.String()
+
+	// The archived body stays as sent; only parsing sees it decompressed.
+	raw, err := f.body.Reader()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode %s: %v\n", pageUrl, err)
+		return
+	}
+	content, err := fetcher.DecodeReader(f.resp.Header.Get("Content-Encoding"), raw, self.cfg.MaxBodySize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode %s: %v\n", pageUrl, err)
+		return
+	}
+	decoded, err := charset.Decode(content, f.resp.Header.Get("Content-Type"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode %s: %v\n", pageUrl, err)
+		return
+	}
+	parsed := soup.HTMLParse(decoded.Text)
+
+	directives := robotsmeta.ParseHeader(self.cfg.UserAgent, f.resp.Header.Values("X-Robots-Tag"))
+	directives.Merge(robotsmeta.ParseMeta(self.cfg.UserAgent, parsed.Pointer))
+
+	outlinks := linkExtractor.ExtractNode(u, parsed.Pointer)
+	headerLinks := links.ParseLinkHeader(u, f.resp.Header.Values("Link"))
+
+	if !directives.NoFollow {
+		for _, link := range outlinks {
+			if link.HasRel("nofollow") {
+				continue
+			}
+			self.Enqueue(frontierItem{URL: link.URL, Via: pageUrl, Seed: item.Seed, Depth: item.Depth + 1})
+		}
+	}
+
+	canonical, hasCanonical := links.Canonical(headerLinks, outlinks)
+	if hasCanonical {
+		self.Enqueue(frontierItem{URL: canonical, Via: pageUrl, Seed: item.Seed, Depth: item.Depth + 1})
+	}
+
+	if directives.NoIndex || directives.Expired(time.Now()) {
+		return
+	}
+	if self.canonicals.Covered(pageUrl, canonical) {
+		return
+	}
+
+	fields := captureFields(via, f)
+	fields.Add("charset", decoded.Encoding)
+	fields.Add("robots", "allowed")
+	if d := directives.String(); d != "" {
+		fields.Add("robots-directives", d)
+	}
+	if hasCanonical {
+		fields.Add("canonical", canonical)
+	}
+	for _, link := range outlinks {
+		fields.Add("outlink", fmt.Sprintf("%s %s", link.URL, link.Source))
+	}
+	doc := contentExtractor.ExtractNode(parsed.Pointer)
+	hints := slices.Clone(doc.LanguageHints)
+	for _, value := range f.resp.Header.Values("Content-Language") {
+		hints = append(hints, strings.Split(value, ",")...)
+	}
+	if lang := self.languages.Identify(doc.Title+"\n"+doc.Text, hints...); lang.Language != "" {
+		fields.Add("language", lang.Language)
+		fields.Add("language-confidence", strconv.FormatFloat(lang.Confidence, 'f', 3, 64))
+	}
+	if fp, ok := self.nearDups.Fingerprint(doc.Text); ok {
+		fields.Add("simhash", fmt.Sprintf("%016x", fp.SimHash))
+		if matches := self.nearDups.Add(pageUrl, fp); len(matches) > 0 {
+			best := matches[0]
+			// The page matched is recorded rather than its group, whose
+			// first page can change as groups merge; the groups as they
+			// end up are kept in the checkpoint.
+			fields.Add("near-duplicate-of", best.ID)
+			fields.Add("near-duplicate", fmt.Sprintf("distance=%d similarity=%.2f", best.Distance, best.Similarity))
+		}
+	}
+
+	// A page marked noarchive may not be kept, so only what was learned
+	// from it is recorded.
+	if directives.NoArchive {
+		if err := self.storeMetadata(pageUrl, f, fields); err != nil {
+			return
+		}
+	} else if !self.archive(pageUrl, f, fields, doc) {
+		return
+	}
+	self.canonicals.Add(pageUrl, canonical)
+	self.history.Set(pageUrl, pageState{
+		Seed:         item.Seed,
+		Depth:        item.Depth,
+		ETag:         f.resp.Header.Get("ETag"),
+		LastModified: f.resp.Header.Get("Last-Modified"),
+		Digest:       f.digest,
+	})
+	self.scheduler.Observe(pageUrl, f.start, f.digest)
+
+	self.pages.Add(1)
+}
+
+// archive writes the response, or a revisit when its body is already archived
+// under this URL or another, along with the content extracted from it.
+// Truncated and empty bodies are not shared across URLs, as their digests say
+// nothing about the full content.
+func (self *crawler) archive(pageUrl string, f *fetchResult, fields warc.Header, doc extract.Document) bool {
+	shareable := f.truncated == "" && f.body.Size() > 0
+	original, duplicate := self.captures.Lookup(pageUrl, f.digest)
+	if !duplicate && shareable {
+		original, duplicate = self.digests.Lookup(f.digest)
+	}
+	var response *warc.Record
+	if duplicate {
+		response = warc.NewRevisitRecord(pageUrl, warc.ProfileIdenticalPayloadDigest, original)
+		response.HTTPHeader = f.responseHeader()
+	} else {
+		var err error
+		if response, err = newResponseRecord(pageUrl, f); err != nil {
+			fmt.Fprintf(os.Stderr, "archive %s: %v\n", pageUrl, err)
+			return false
+		}
+	}
+
+	var derived []*warc.Record
+	if extracted, err := json.Marshal(doc); err == nil {
+		derived = append(derived, warc.NewConversionRecord(pageUrl, response, "application/json", bytes.NewReader(extracted)))
+	}
+
+	if _, err := self.store(pageUrl, f, response, fields, derived...); err != nil {
+		return false
+	}
+	if duplicate {
+		self.captures.Set(pageUrl, original)
+	} else {
+		original = self.captures.Add(response)
+	}
+	if shareable {
+		self.digests.Add(pageUrl, original)
+	}
+
+	return true
+}
+
+// addValidators makes a revisit conditional when an earlier capture of the URL
+// can be pointed to should the server answer 304.
+func (self *crawler) addValidators(req *http.Request) bool {
+	targetURI := req.URL.String()
+	state, ok := self.history.Get(targetURI)
+	if !ok || (state.ETag == "" && state.LastModified == "") {
+		return false
+	}
+	if _, ok := self.captures.Get(targetURI); !ok {
+		return false
+	}
+
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	return true
+}
+
+// A 304 is archived as a server-not-modified revisit of the earlier capture;
+// nothing is parsed, so the page's links come from the previous crawl.
+func (self *crawler) notModified(via string, u *url.URL, f *fetchResult) {
+	pageUrl := u.String()
+
+	original, ok := self.captures.Get(pageUrl)
+	if !ok {
+		return
+	}
+	response := warc.NewRevisitRecord(pageUrl, warc.ProfileServerNotModified, original)
+	response.HTTPHeader = f.responseHeader()
+
+	if _, err := self.store(pageUrl, f, response, captureFields(via, f)); err != nil {
+		return
+	}
+
+	if state, ok := self.history.Get(pageUrl); ok {
+		if etag := f.resp.Header.Get("ETag"); etag != "" {
+			state.ETag = etag
+		}
+		if lastModified := f.resp.Header.Get("Last-Modified"); lastModified != "" {
+			state.LastModified = lastModified
+		}
+		self.history.Set(pageUrl, state)
+		self.scheduler.Observe(pageUrl, f.start, state.Digest)
+	}
+
+	self.pages.Add(1)
+}
+
+func newResponseRecord(targetURI string, f *fetchResult) (*warc.Record, error) {
+	payload, err := f.body.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	response := warc.NewRecord(warc.TypeResponse)
+	response.Header.Set(warc.FieldTargetURI, targetURI)
+	response.Header.Set(warc.FieldContentType, "application/http; msgtype=response")
+	response.HTTPHeader = f.responseHeader()
+	response.Payload = payload
+	if f.truncated != "" {
+		response.Header.Set(warc.FieldTruncated, f.truncated)
+	}
+
+	return response, nil
+}
+
+// responseHeader is the header block as received, or one rebuilt from the
+// parsed response when it could not be recorded. The body is stored without
+// its transfer coding, so a chunked block is rewritten to describe it; the
+// original coding goes in the capture's metadata.
+func (self *fetchResult) responseHeader() []byte {
+	if self.rawResp != nil && self.chunked() {
+		return warc.DechunkedHeader(self.rawResp, self.body.Size())
+	}
+	if self.rawResp != nil {
+		return self.rawResp
+	}
+
+	return warc.HTTPResponseHeader(self.resp)
+}
+
+func (self *fetchResult) chunked() bool {
+	return len(self.resp.TransferEncoding) > 0
+}
+
+func captureFields(via string, f *fetchResult) warc.Header {
+	var fields warc.Header
+	if via != "" {
+		fields.Add("via", via)
+	}
+	if f.rawResp != nil && f.chunked() {
+		fields.Add("http-transfer-encoding", strings.Join(f.resp.TransferEncoding, ", "))
+	}
+	fields.Add("fetchTimeMs", strconv.FormatInt(f.duration.Milliseconds(), 10))
+	if f.ip != "" {
+		fields.Add("ip", f.ip)
+	}
+
+	return fields
+}
+
+// store writes the response with its request and metadata records, followed by
+// any records derived from it.
+func (self *crawler) store(targetURI string, f *fetchResult, response *warc.Record, fields warc.Header, derived ...*warc.Record) ([]warc.Location, error) {
+	response.Header.Set(warc.FieldDate, warc.FormatDate(f.start))
+	if f.ip != "" {
+		response.Header.Set(warc.FieldIPAddress, f.ip)
+	}
+
+	request := warc.NewRequestRecord(targetURI, f.req)
+	if f.rawReq != nil {
+		request.HTTPHeader = f.rawReq
+	}
+	request.Header.Set(warc.FieldDate, response.Header.Get(warc.FieldDate))
+	request.Header.Set(warc.FieldConcurrentTo, response.ID())
+
+	metadata := warc.NewMetadataRecord(targetURI, fields)
+	metadata.Header.Set(warc.FieldDate, response.Header.Get(warc.FieldDate))
+	metadata.Header.Set(warc.FieldConcurrentTo, response.ID())
+
+	records := []*warc.Record{response, request, metadata}
+	for _, rec := range derived {
+		rec.Header.Set(warc.FieldDate, response.Header.Get(warc.FieldDate))
+		records = append(records, rec)
+	}
+
+	return self.write(records...)
+}
+
+// storeMetadata writes the metadata record of a page whose response is not
+// archived.
+func (self *crawler) storeMetadata(targetURI string, f *fetchResult, fields warc.Header) error {
+	metadata := warc.NewMetadataRecord(targetURI, fields)
+	metadata.Header.Set(warc.FieldDate, warc.FormatDate(f.start))
+	_, err := self.write(metadata)
+
+	return err
+}
+
+func (self *crawler) write(records ...*warc.Record) ([]warc.Location, error) {
+	locations, err := self.warcFiles.WriteRecords(records...)
+	if err != nil {
+		return nil, err
+	}
+	for _, loc := range locations {
+		self.bytes.Add(loc.Length)
+	}
+
+	return locations, nil
+}
+
+func getDomain(url string) (string, error) {
+	parts := strings.Split(url, "/")
+	if len(parts) < 3 {
+		return "", fmt.Errorf("invalid URL")
+	}
+	return strings.Join(parts[:3], "/"), nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guilherme13c/go-search/utils/scope"
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+type archived struct {
+	Type    warc.RecordType
+	Header  warc.Header
+	Payload string
+}
+
+// crawlSite crawls the site served by handler from its root and returns the
+// records written, by target URI.
+func crawlSite(t *testing.T, handler http.Handler) map[string][]archived {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	cfg := defaultConfig()
+	cfg.OutputDir = t.TempDir()
+	cfg.Workers = 4
+
+	warcFiles, err := warc.NewFileManager(warc.FileManagerOptions{Dir: cfg.OutputDir, Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	c, err := newCrawler(cfg, warcFiles)
+	if err != nil {
+		t.Fatalf("newCrawler error = %v", err)
+	}
+	seed := server.URL + "/"
+	if err := c.AddSeed(scope.Seed{URL: seed}); err != nil {
+		t.Fatalf("AddSeed error = %v", err)
+	}
+	c.Enqueue(frontierItem{URL: seed, Seed: seed})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if reason := c.Run(ctx); !errors.Is(reason, errFrontierExhausted) {
+		t.Fatalf("Run = %v; want %v", reason, errFrontierExhausted)
+	}
+	if err := warcFiles.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	res := make(map[string][]archived)
+	names, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "*.warc.gz"))
+	for _, name := range names {
+		r, err := warc.OpenFile(name)
+		if err != nil {
+			t.Fatalf("OpenFile error = %v", err)
+		}
+		for rec, err := range r.Records() {
+			if err != nil {
+				t.Fatalf("read %s error = %v", name, err)
+			}
+			// Responses are read back through their HTTP header block, as
+			// a replay tool would.
+			body := rec.Payload
+			if rec.Type() == warc.TypeResponse {
+				resp, err := rec.HTTPResponse()
+				if err != nil {
+					t.Fatalf("HTTPResponse error = %v", err)
+				}
+				body = resp.Body
+			}
+			payload, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("read %s payload error = %v", rec.Header.Get(warc.FieldTargetURI), err)
+			}
+			targetURI := strings.TrimPrefix(rec.Header.Get(warc.FieldTargetURI), server.URL)
+			res[targetURI] = append(res[targetURI], archived{Type: rec.Type(), Header: rec.Header, Payload: string(payload)})
+		}
+		r.Close()
+	}
+
+	return res
+}
+
+func recordTypes(records []archived) []warc.RecordType {
+	res := []warc.RecordType{}
+	for _, rec := range records {
+		res = append(res, rec.Type)
+	}
+
+	return res
+}
+
+func TestCrawl_NoArchive(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			io.WriteString(w, `<html><body><a href="/kept">kept</a> <a href="/meta">meta</a> <a href="/header">header</a></body></html>`)
+		case "/meta":
+			io.WriteString(w, `<html><head><meta name="robots" content="noarchive"></head><body><p>Not to be kept.</p></body></html>`)
+		case "/header":
+			w.Header().Set("X-Robots-Tag", "noarchive")
+			io.WriteString(w, `<html><body><p>Not to be kept either.</p></body></html>`)
+		default:
+			io.WriteString(w, `<html><body><p>Keep this one.</p></body></html>`)
+		}
+	})
+
+	records := crawlSite(t, mux)
+
+	if got := recordTypes(records["/kept"]); len(got) == 0 || got[0] != warc.TypeResponse {
+		t.Errorf("/kept records = %v; want a response", got)
+	}
+	for _, path := range []string{"/meta", "/header"} {
+		got := recordTypes(records[path])
+		if len(got) != 1 || got[0] != warc.TypeMetadata {
+			t.Errorf("%s records = %v; want only metadata", path, got)
+			continue
+		}
+		if fields := records[path][0].Payload; !strings.Contains(fields, "robots-directives: noarchive\r\n") {
+			t.Errorf("%s metadata = %q; want the noarchive directive recorded", path, fields)
+		}
+	}
+}
+
+func TestCrawl_RedirectNormalized(t *testing.T) {
+	var mu sync.Mutex
+	fetches := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<html><body><a href="/page">page</a> <a href="/old">old</a></body></html>`)
+		case r.URL.Path == "/old":
+			http.Redirect(w, r, "/page;jsessionid=0123456789ABCDEF", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, "/page"):
+			mu.Lock()
+			fetches++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<html><body><p>The page.</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	records := crawlSite(t, mux)
+
+	if fetches != 1 {
+		t.Errorf("page fetched %d times; want once, the redirect target normalized to the same URL", fetches)
+	}
+	if _, ok := records["/page;jsessionid=0123456789ABCDEF"]; ok {
+		t.Error("redirect target archived with its session ID; want it normalized")
+	}
+}
+
+func TestCrawl_CrossHostRedirectChain(t *testing.T) {
+	var mu sync.Mutex
+	hops := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Every hop lands on a fresh URL on the other host, so only the
+		// redirect limit can end the chain.
+		host, port, _ := strings.Cut(r.Host, ":")
+		other := "localhost"
+		if host == "localhost" {
+			other = "127.0.0.1"
+		}
+		mu.Lock()
+		hops++
+		n := hops
+		mu.Unlock()
+		http.Redirect(w, r, fmt.Sprintf("http://%s:%s/hop/%d", other, port, n), http.StatusFound)
+	})
+
+	crawlSite(t, mux)
+
+	if want := defaultConfig().MaxRedirects + 1; hops != want {
+		t.Errorf("followed %d redirects across hosts; want %d, the chain limit", hops, want)
+	}
+}
+
+func TestCrawl_RetryAfterBeyondBudget(t *testing.T) {
+	var mu sync.Mutex
+	fetches := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// Run returns once the frontier is exhausted, which it only is when the
+	// page is given up rather than held back for an hour.
+	crawlSite(t, mux)
+
+	if fetches != 1 {
+		t.Errorf("page fetched %d times; want once", fetches)
+	}
+}
+
+func TestCrawl_ChunkedResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "User-agent: *\nAllow: /\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html><body><p>First chunk.</p>")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "<p>Second chunk.</p></body></html>")
+	})
+
+	records := crawlSite(t, mux)
+
+	got := records["/"]
+	if len(got) == 0 || got[0].Type != warc.TypeResponse {
+		t.Fatalf("/ records = %v; want a response", recordTypes(got))
+	}
+	if want := "<html><body><p>First chunk.</p><p>Second chunk.</p></body></html>"; got[0].Payload != want {
+		t.Errorf("payload = %q; want %q", got[0].Payload, want)
+	}
+	for _, rec := range got {
+		if rec.Type == warc.TypeMetadata && !strings.Contains(rec.Payload, "http-transfer-encoding: chunked\r\n") {
+			t.Errorf("metadata = %q; want the original transfer coding recorded", rec.Payload)
+		}
+	}
+}
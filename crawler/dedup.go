@@ -0,0 +1,90 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+// digestIndex finds the first capture of each payload digest, so a body
+// already archived under another URL is written as a revisit, and groups the
+// URLs serving the same body into duplicate clusters.
+type digestIndex struct {
+	mu        sync.Mutex
+	originals map[string]*warc.Record
+	clusters  map[string][]string
+}
+
+func newDigestIndex() *digestIndex {
+	return &digestIndex{
+		mu:        sync.Mutex{},
+		originals: make(map[string]*warc.Record),
+		clusters:  make(map[string][]string),
+	}
+}
+
+func (self *digestIndex) Lookup(payloadDigest string) (*warc.Record, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	original, ok := self.originals[payloadDigest]
+	return original, ok
+}
+
+// Add records that targetURI served the body of original, which becomes the
+// body's original capture if it is the first.
+func (self *digestIndex) Add(targetURI string, original *warc.Record) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	payloadDigest := original.Header.Get(warc.FieldPayloadDigest)
+	if _, ok := self.originals[payloadDigest]; !ok {
+		self.originals[payloadDigest] = original
+	}
+	if !slices.Contains(self.clusters[payloadDigest], targetURI) {
+		self.clusters[payloadDigest] = append(self.clusters[payloadDigest], targetURI)
+	}
+}
+
+func (self *digestIndex) Snapshot() map[string][]string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make(map[string][]string, len(self.clusters))
+	for payloadDigest, urls := range self.clusters {
+		res[payloadDigest] = slices.Clone(urls)
+	}
+
+	return res
+}
+
+// Restore rebuilds the index from a checkpoint. The originals come from the
+// captures, where each response is stored under its own target URI.
+func (self *digestIndex) Restore(captures map[string][]warc.Field, clusters map[string][]string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, targetURI := range slices.Sorted(maps.Keys(captures)) {
+		fields := captures[targetURI]
+		original := &warc.Record{}
+		for _, f := range fields {
+			original.Header.Add(f.Name, f.Value)
+		}
+		payloadDigest := original.Header.Get(warc.FieldPayloadDigest)
+		if payloadDigest == "" || original.Header.Get(warc.FieldTargetURI) != targetURI {
+			continue
+		}
+		if _, ok := self.originals[payloadDigest]; !ok {
+			self.originals[payloadDigest] = original
+		}
+	}
+	for payloadDigest, urls := range clusters {
+		for _, targetURI := range urls {
+			if !slices.Contains(self.clusters[payloadDigest], targetURI) {
+				self.clusters[payloadDigest] = append(self.clusters[payloadDigest], targetURI)
+			}
+		}
+	}
+}
@@ -5,7 +5,9 @@ go 1.24.2
 require github.com/guilherme13c/go-search/utils v0.0.0-20250428124800-cd45620b364c
 
 require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/anaskhan96/soup v1.2.5 // indirect
+	github.com/andybalholm/brotli v1.1.1 // indirect
 	github.com/bits-and-blooms/bitset v1.5.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/jimsmart/grobotstxt v1.0.3 // indirect
@@ -18,4 +20,5 @@ require (
 	golang.org/x/net v0.23.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
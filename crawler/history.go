@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+)
+
+// pageState is what is remembered about an archived URL between crawls: where
+// it was found and the validators needed to re-fetch it conditionally.
+type pageState struct {
+	Seed         string
+	Depth        int
+	ETag         string
+	LastModified string
+	Digest       string
+}
+
+type historyIndex struct {
+	mu    sync.Mutex
+	byURL map[string]pageState
+}
+
+func newHistoryIndex() *historyIndex {
+	return &historyIndex{
+		mu:    sync.Mutex{},
+		byURL: make(map[string]pageState),
+	}
+}
+
+func (self *historyIndex) Get(targetURI string) (pageState, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	state, ok := self.byURL[targetURI]
+	return state, ok
+}
+
+func (self *historyIndex) Set(targetURI string, state pageState) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.byURL[targetURI] = state
+}
+
+func (self *historyIndex) Snapshot() map[string]pageState {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make(map[string]pageState, len(self.byURL))
+	for targetURI, state := range self.byURL {
+		res[targetURI] = state
+	}
+
+	return res
+}
+
+func (self *historyIndex) Restore(history map[string]pageState) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for targetURI, state := range history {
+		self.byURL[targetURI] = state
+	}
+}
@@ -1,179 +1,142 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
-	"strings"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/anaskhan96/soup"
-	"github.com/google/uuid"
-
-	lrucache "github.com/guilherme13c/go-search/utils/lru-cache"
-	"github.com/guilherme13c/go-search/utils/queue"
-	robotstxt "github.com/guilherme13c/go-search/utils/robots-txt"
-	"github.com/guilherme13c/go-search/utils/set"
-)
-
-const (
-	userAgent    = "go-search-bot/0.0.1"
-	maxCacheSize = 1024
-)
-
-var (
-	robotsCache = lrucache.NewLruCache[string, robotstxt.Robotstxt](maxCacheSize)
-	robotsMu    = sync.Mutex{}
+	"github.com/guilherme13c/go-search/utils/warc"
 )
 
 func main() {
-	os.RemoveAll("corpus/")
-	os.Mkdir("corpus", 0777)
-
-	frontier := queue.NewQueue[string](0)
-	visited := set.NewSet[string]()
-	semaphore := make(chan struct{}, 512)
-
-	seedFile, errOpenSeedFile := os.Open("crawler/seeds.txt")
-	if errOpenSeedFile != nil {
-		panic(errOpenSeedFile)
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
-	defer seedFile.Close()
-
-	scanner := bufio.NewScanner(seedFile)
-	for scanner.Scan() {
-		frontier.Put(scanner.Text())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	robotsParser := robotstxt.NewParser(&http.Client{Timeout: time.Second * 5})
-
-	run := true
-
-	for run {
-		semaphore <- struct{}{}
-		go func() {
-			defer func() { <-semaphore }()
-			_, cancel := context.WithTimeout(context.Background(), time.Second*5)
-			defer cancel()
-
-			pageUrl, ok := frontier.Get()
-			if !ok {
-				return
-			}
-
-			domain, err := getDomain(pageUrl)
-			if err != nil {
-				return
-			}
-
-			var robotsTxt robotstxt.Robotstxt
-			{
-				robotsMu.Lock()
-				defer robotsMu.Unlock()
+	if cfg.Reset {
+		if err := os.RemoveAll(cfg.OutputDir); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		panic(err)
+	}
 
-				robotRules, exists := robotsCache.Get(domain)
-				if !exists {
-					rules, err := robotsParser.FetchAndParse(domain)
-					if err != nil || rules == nil {
-						return
-					}
+	var resumeFrom *checkpoint
+	if cfg.Resume {
+		cp, err := readCheckpoint(cfg.CheckpointDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		resumeFrom = &cp
+	}
+	var recrawlFrom *checkpoint
+	if cfg.Recrawl && !cfg.Resume {
+		cp, err := readCheckpoint(cfg.CheckpointDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		recrawlFrom = &cp
+	}
 
-					robotsCache.Put(domain, *rules)
-					robotRules = rules
-				}
-				robotsTxt = *robotRules
-			}
-			fmt.Printf("%#v\n", robotsTxt)
+	serial, err := nextWarcSerial(cfg.OutputDir, cfg.WarcPrefix)
+	if err != nil {
+		panic(err)
+	}
+	for _, cp := range []*checkpoint{resumeFrom, recrawlFrom} {
+		if cp != nil {
+			serial = max(serial, cp.WarcSerial)
+		}
+	}
 
-			u, err := url.Parse(pageUrl)
-			if err != nil {
-				return
-			}
-			if u.Scheme == "" {
-				u.Scheme = "https"
-			}
+	var warcInfo warc.Header
+	warcInfo.Set("software", cfg.UserAgent)
+	warcInfo.Set("http-header-user-agent", cfg.UserAgent)
+	warcFiles, err := warc.NewFileManager(warc.FileManagerOptions{
+		Dir:      cfg.OutputDir,
+		Prefix:   cfg.WarcPrefix,
+		MaxSize:  cfg.WarcMaxSize,
+		Compress: true,
+		Serial:   serial,
+		Info:     warcInfo,
+	})
+	if err != nil {
+		panic(err)
+	}
 
-			client := http.Client{Timeout: time.Second * 5}
-			resp, err := client.Get(u.String())
-			if err != nil {
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				return
-			}
-			contentType := resp.Header.Get("Content-Type")
-			if !strings.Contains(contentType, "text/html") &&
-				!strings.Contains(contentType, "application/xhtml+xml") &&
-				!strings.Contains(contentType, "application/xml") {
-				return
-			}
+	c, err := newCrawler(cfg, warcFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return
-			}
-			body := string(bodyBytes)
+	seeds, err := readSeeds(cfg.SeedsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, seed := range seeds {
+		if err := c.AddSeed(seed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
-			parsed := soup.HTMLParse(body)
-			links := parsed.FindAll("a")
-			for _, link := range links {
-				extractedUrl, ok := link.Attrs()["href"]
-				if !ok {
-					continue
-				}
-				if strings.HasPrefix(extractedUrl, "/") || strings.HasPrefix(extractedUrl, "#") {
-					extractedUrl = domain + extractedUrl
-				}
-				if visited.Contains(pageUrl) {
-					continue
-				}
-				frontier.Put(extractedUrl)
-			}
+	if resumeFrom != nil {
+		if err := c.Restore(*resumeFrom); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else {
+		for _, seed := range seeds {
+			c.Enqueue(frontierItem{URL: seed.URL, Seed: seed.URL})
+		}
+	}
+	if recrawlFrom != nil {
+		n := c.LoadHistory(*recrawlFrom)
+		fmt.Fprintf(os.Stderr, "recrawl: queued %d of %d pages archived by the crawl of %s\n", n, len(recrawlFrom.History), recrawlFrom.Time.Format(time.RFC3339))
+	}
 
-			docId := uuid.NewString()
-			file, err := os.Create("corpus/" + docId + ".warc")
-			if err != nil {
-				return
-			}
-			defer file.Close()
+	c.publishMetrics(cfg.MetricsAddr)
 
-			writer := bufio.NewWriter(file)
-			defer writer.Flush()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(cfg.MaxDuration), errMaxDuration)
+		defer cancel()
+	}
+	// Restore the default handlers once stopping so a second signal kills the process.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	reason := c.Run(ctx)
+	fmt.Fprintf(os.Stderr, "crawl stopped: %v (%d pages, %d bytes)\n", reason, c.pages.Load(), c.bytes.Load())
+	if summary := c.trapSummary(); summary != "" {
+		fmt.Fprintf(os.Stderr, "traps: %s\n", summary)
+	}
 
-			warcDate := time.Now().UTC().Format("2006-01-02T15:04:05Z")
-			recordId := uuid.New().String()
-			headers := []string{
-				"WARC/1.0",
-				"WARC-Type: response",
-				fmt.Sprintf("WARC-Date: %s", warcDate),
-				fmt.Sprintf("WARC-Record-ID: <urn:uuid:%s>", recordId),
-				fmt.Sprintf("WARC-Target-URI: %s", pageUrl),
-				"Content-Type: application/http; msgtype=response",
-				fmt.Sprintf("Content-Length: %d", len(bodyBytes)+len(contentType)+50),
-				"",
-				fmt.Sprintf("HTTP/1.1 %s", resp.Status),
-			}
-			for k, v := range resp.Header {
-				headers = append(headers, fmt.Sprintf("%s: %s", k, strings.Join(v, ", ")))
-			}
-			headers = append(headers, "", body)
-			for _, line := range headers {
-				writer.WriteString(line + "\r\n")
-			}
-		}()
+	if err := warcFiles.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
 
-func getDomain(url string) (string, error) {
-	parts := strings.Split(url, "/")
-	if len(parts) < 3 {
-		return "", fmt.Errorf("invalid URL")
+	if err := c.SaveCheckpoint(reason.Error()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	return strings.Join(parts[:3], "/"), nil
 }
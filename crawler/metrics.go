@@ -0,0 +1,59 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/traps"
+)
+
+// publishMetrics exposes the crawl counters through expvar and, when addr is
+// set, serves them at /debug/vars.
+func (self *crawler) publishMetrics(addr string) {
+	expvar.Publish("crawl", expvar.Func(func() any {
+		return map[string]int64{
+			"pages":     self.pages.Load(),
+			"bytes":     self.bytes.Load(),
+			"in_flight": self.inFlight.Load(),
+		}
+	}))
+	expvar.Publish("traps", expvar.Func(func() any {
+		return self.traps.Counts()
+	}))
+	expvar.Publish("normalized_session_params", expvar.Func(func() any {
+		return self.traps.Normalized()
+	}))
+
+	if addr == "" {
+		return
+	}
+	go func() {
+		if err := http.ListenAndServe(addr, expvar.Handler()); err != nil {
+			fmt.Fprintf(os.Stderr, "metrics server error: %v\n", err)
+		}
+	}()
+}
+
+// trapSummary lists the trap rules that fired, in rule order, then the URLs
+// stripped of session parameters, which are kept rather than rejected.
+func (self *crawler) trapSummary() string {
+	counts := self.traps.Counts()
+	parts := []string{}
+	for _, rule := range traps.Rules {
+		if n := counts[rule]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", rule, n))
+		}
+	}
+	summary := strings.Join(parts, ", ")
+	if n := self.traps.Normalized(); n > 0 {
+		if summary != "" {
+			summary += "; "
+		}
+		summary += fmt.Sprintf("normalized_session_params %d", n)
+	}
+
+	return summary
+}
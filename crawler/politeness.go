@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ipSlots caps concurrent fetches per IP address, so hosts sharing a server
+// are not hammered together. Slot channels are created on first use.
+type ipSlots struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+func newIPSlots(limit int) *ipSlots {
+	return &ipSlots{
+		mu:    sync.Mutex{},
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+func (self *ipSlots) Acquire(ctx context.Context, ip string) (func(), error) {
+	self.mu.Lock()
+	slot, ok := self.slots[ip]
+	if !ok {
+		slot = make(chan struct{}, self.limit)
+		self.slots[ip] = slot
+	}
+	self.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// acquireIP waits for a fetch slot on host's first address when per-IP
+// politeness is enabled. The lookup is served from the resolver cache, so the
+// connection that follows dials the same address.
+func (self *crawler) acquireIP(ctx context.Context, host string) (func(), error) {
+	if self.ipSlots == nil {
+		return func() {}, nil
+	}
+
+	addrs, err := self.resolver.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	return self.ipSlots.Acquire(ctx, addrs[0].String())
+}
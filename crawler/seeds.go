@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/scope"
+)
+
+// Each line of the seeds file is a URL optionally followed by per-seed
+// overrides, e.g. "https://example.com/ scope=domain hops=3". Blank lines and
+// lines starting with # are ignored.
+func readSeeds(path string) ([]scope.Seed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open seeds error: %w", err)
+	}
+	defer file.Close()
+
+	res := []scope.Seed{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		seed := scope.Seed{URL: fields[0]}
+		if !strings.Contains(seed.URL, "://") {
+			seed.URL = "https://" + seed.URL
+		}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "scope":
+				seed.Mode = scope.Mode(value)
+			case "hops":
+				hops, err := strconv.Atoi(value)
+				if err != nil || hops < 0 {
+					return nil, fmt.Errorf("%s:%d: invalid hops %q", path, n, value)
+				}
+				seed.MaxHops = hops
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown seed option %q", path, n, field)
+			}
+		}
+		res = append(res, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read seeds error: %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,326 @@
+package cdxj
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/surt"
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+const timestampLayout = "20060102150405"
+
+type Entry struct {
+	Key       string
+	Timestamp string
+	URL       string
+	MIME      string
+	Status    string
+	Digest    string
+	Filename  string
+	Offset    int64
+	Length    int64
+}
+
+type fields struct {
+	URL      string `json:"url"`
+	MIME     string `json:"mime,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	Length   string `json:"length"`
+	Offset   string `json:"offset"`
+	Filename string `json:"filename"`
+}
+
+func (self Entry) String() string {
+	b, _ := json.Marshal(fields{
+		URL:      self.URL,
+		MIME:     self.MIME,
+		Status:   self.Status,
+		Digest:   self.Digest,
+		Length:   strconv.FormatInt(self.Length, 10),
+		Offset:   strconv.FormatInt(self.Offset, 10),
+		Filename: self.Filename,
+	})
+
+	return self.Key + " " + self.Timestamp + " " + string(b)
+}
+
+func ParseLine(line string) (Entry, error) {
+	key, rest, ok := strings.Cut(strings.TrimSpace(line), " ")
+	if !ok {
+		return Entry{}, fmt.Errorf("invalid CDXJ line %q", line)
+	}
+	timestamp, rest, ok := strings.Cut(rest, " ")
+	if !ok {
+		return Entry{}, fmt.Errorf("invalid CDXJ line %q", line)
+	}
+
+	var f fields
+	if err := json.Unmarshal([]byte(rest), &f); err != nil {
+		return Entry{}, fmt.Errorf("invalid CDXJ fields: %w", err)
+	}
+	offset, errOffset := strconv.ParseInt(f.Offset, 10, 64)
+	length, errLength := strconv.ParseInt(f.Length, 10, 64)
+	if err := errors.Join(errOffset, errLength); err != nil {
+		return Entry{}, fmt.Errorf("invalid CDXJ offset or length: %w", err)
+	}
+
+	return Entry{
+		Key:       key,
+		Timestamp: timestamp,
+		URL:       f.URL,
+		MIME:      f.MIME,
+		Status:    f.Status,
+		Digest:    f.Digest,
+		Filename:  f.Filename,
+		Offset:    offset,
+		Length:    length,
+	}, nil
+}
+
+func IndexFile(path string) ([]Entry, error) {
+	r, err := warc.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	res := []Entry{}
+	for rec, err := range r.Records() {
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", path, err)
+		}
+
+		entry, ok := newEntry(rec)
+		if !ok {
+			continue
+		}
+		loc := r.Location()
+		entry.Filename = loc.Filename
+		entry.Offset = loc.Offset
+		entry.Length = loc.Length
+		res = append(res, entry)
+	}
+
+	return res, nil
+}
+
+func Sort(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Key != entries[j].Key {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Timestamp < entries[j].Timestamp
+	})
+}
+
+func Write(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		if _, err := bw.WriteString(e.String() + "\n"); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func Read(r io.Reader) ([]Entry, error) {
+	res := []Entry{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			entry, errParse := ParseLine(line)
+			if errParse != nil {
+				return res, errParse
+			}
+			res = append(res, entry)
+		}
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, fmt.Errorf("read error: %w", err)
+		}
+	}
+}
+
+// Cluster holds the captures of one payload under different URLs. Original is
+// the earliest capture holding the payload itself, so an indexer can index the
+// body once and attach the other URLs to it.
+type Cluster struct {
+	Digest   string
+	Original Entry
+	Entries  []Entry
+}
+
+func (self Cluster) URLs() []string {
+	res := []string{self.Original.URL}
+	for _, e := range self.Entries {
+		if !slices.Contains(res, e.URL) {
+			res = append(res, e.URL)
+		}
+	}
+
+	return res
+}
+
+// Duplicates groups entries by payload digest and returns, ordered by digest,
+// the groups that span more than one URL.
+func Duplicates(entries []Entry) []Cluster {
+	byDigest := make(map[string][]Entry)
+	for _, e := range entries {
+		if e.Digest != "" {
+			byDigest[e.Digest] = append(byDigest[e.Digest], e)
+		}
+	}
+
+	res := []Cluster{}
+	for _, digest := range slices.Sorted(maps.Keys(byDigest)) {
+		group := byDigest[digest]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Timestamp < group[j].Timestamp })
+
+		cluster := Cluster{Digest: digest, Original: group[0], Entries: group}
+		for _, e := range group {
+			if e.MIME != "warc/revisit" {
+				cluster.Original = e
+				break
+			}
+		}
+		if len(cluster.URLs()) > 1 {
+			res = append(res, cluster)
+		}
+	}
+
+	return res
+}
+
+func Fetch(dir string, entry Entry) (*warc.Record, io.Closer, error) {
+	file, err := os.Open(filepath.Join(dir, entry.Filename))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open error: %w", err)
+	}
+
+	r, err := warc.NewReader(io.NewSectionReader(file, entry.Offset, entry.Length))
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	rec, err := r.Next()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("read record error: %w", err)
+	}
+
+	return rec, file, nil
+}
+
+// Resolve returns the record holding the payload of entry: its own record,
+// or for a revisit the capture it refers to. The original is looked up in idx
+// by its target URI and date and checked against WARC-Refers-To.
+func Resolve(idx Index, dir string, entry Entry) (*warc.Record, io.Closer, error) {
+	rec, closer, err := Fetch(dir, entry)
+	if err != nil || rec.Type() != warc.TypeRevisit {
+		return rec, closer, err
+	}
+	closer.Close()
+
+	refersTo := rec.Header.Get(warc.FieldRefersTo)
+	targetURI := rec.Header.Get(warc.FieldRefersToTargetURI)
+	if targetURI == "" {
+		return nil, nil, fmt.Errorf("revisit of %s names no original", entry.URL)
+	}
+	var timestamp string
+	if date, err := warc.ParseDate(rec.Header.Get(warc.FieldRefersToDate)); err == nil {
+		timestamp = date.UTC().Format(timestampLayout)
+	}
+
+	candidates, err := idx.Lookup(targetURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range slices.Backward(candidates) {
+		if c.URL != targetURI || c.MIME == "warc/revisit" || (timestamp != "" && c.Timestamp != timestamp) {
+			continue
+		}
+		original, closer, err := Fetch(dir, c)
+		if err != nil {
+			return nil, nil, err
+		}
+		if refersTo == "" || original.ID() == refersTo {
+			return original, closer, nil
+		}
+		closer.Close()
+	}
+
+	return nil, nil, fmt.Errorf("original of revisit %s not found: %s", entry.URL, targetURI)
+}
+
+func newEntry(rec *warc.Record) (Entry, bool) {
+	target := rec.Header.Get(warc.FieldTargetURI)
+	if target == "" {
+		return Entry{}, false
+	}
+
+	entry := Entry{URL: target}
+	switch rec.Type() {
+	case warc.TypeResponse, warc.TypeResource:
+	case warc.TypeRevisit:
+		entry.MIME = "warc/revisit"
+	default:
+		return Entry{}, false
+	}
+
+	key, err := surt.Surt(target)
+	if err != nil {
+		return Entry{}, false
+	}
+	entry.Key = key
+
+	if date, err := warc.ParseDate(rec.Header.Get(warc.FieldDate)); err == nil {
+		entry.Timestamp = date.UTC().Format(timestampLayout)
+	}
+
+	digest := rec.Header.Get(warc.FieldPayloadDigest)
+	if digest == "" && rec.Type() == warc.TypeResource {
+		digest = rec.Header.Get(warc.FieldBlockDigest)
+	}
+	entry.Digest = strings.TrimPrefix(digest, "sha1:")
+
+	if rec.HTTPHeader != nil {
+		if resp, err := rec.HTTPResponse(); err == nil {
+			entry.Status = strconv.Itoa(resp.StatusCode)
+			if entry.MIME == "" {
+				entry.MIME = mediaType(resp.Header.Get("Content-Type"))
+			}
+		}
+	} else if entry.MIME == "" {
+		entry.MIME = mediaType(rec.Header.Get(warc.FieldContentType))
+	}
+
+	return entry, true
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
@@ -0,0 +1,269 @@
+package cdxj
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/guilherme13c/go-search/utils/warc"
+)
+
+func newResponse(target string, body string) *warc.Record {
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":   {"text/html; charset=utf-8"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+	}
+
+	rec := warc.NewRecord(warc.TypeResponse)
+	rec.Header.Set(warc.FieldTargetURI, target)
+	rec.Header.Set(warc.FieldContentType, "application/http; msgtype=response")
+	rec.HTTPHeader = warc.HTTPResponseHeader(resp)
+	rec.Payload = strings.NewReader(body)
+
+	return rec
+}
+
+func writeCorpus(t *testing.T, dir string, urls []string) {
+	t.Helper()
+
+	fm, err := warc.NewFileManager(warc.FileManagerOptions{Dir: dir, Prefix: "test", MaxSize: 2048, Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	for i, u := range urls {
+		records := []*warc.Record{newResponse(u, fmt.Sprintf("<html>page %d</html>", i))}
+		records = append(records, warc.NewMetadataRecord(u, warc.Header{}))
+		if _, err := fm.WriteRecords(records...); err != nil {
+			t.Fatalf("WriteRecords error = %v", err)
+		}
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+}
+
+func TestIndexLookupFetch(t *testing.T) {
+	dir := t.TempDir()
+	urls := []string{
+		"https://www.example.com/b",
+		"https://example.org/",
+		"https://example.com/a?y=2&x=1",
+		"https://sub.example.com/",
+		"https://example.com/b",
+	}
+	writeCorpus(t, dir, urls)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	if err != nil || len(files) < 2 {
+		t.Fatalf("expected several WARC files, got %v (err %v)", files, err)
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		e, err := IndexFile(f)
+		if err != nil {
+			t.Fatalf("IndexFile error = %v", err)
+		}
+		entries = append(entries, e...)
+	}
+	if len(entries) != len(urls) {
+		t.Fatalf("indexed %d entries; want %d", len(entries), len(urls))
+	}
+	Sort(entries)
+
+	indexPath := filepath.Join(dir, "index.cdxj")
+	out, err := os.Create(indexPath)
+	if err != nil {
+		t.Fatalf("Create error = %v", err)
+	}
+	if err := Write(out, entries); err != nil {
+		t.Fatalf("Write error = %v", err)
+	}
+	out.Close()
+
+	idx, err := Open(indexPath)
+	if err != nil {
+		t.Fatalf("Open error = %v", err)
+	}
+	defer idx.Close()
+
+	got, err := idx.Lookup("http://example.com/b")
+	if err != nil {
+		t.Fatalf("Lookup error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Lookup(example.com/b) = %d entries; want 2 (www and bare host)", len(got))
+	}
+	for _, e := range got {
+		if e.Key != "com,example)/b" || e.MIME != "text/html" || e.Status != "200" || len(e.Timestamp) != 14 {
+			t.Errorf("entry = %+v", e)
+		}
+	}
+
+	for i, u := range urls {
+		found, err := idx.Lookup(u)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", u, err)
+		}
+		var match *Entry
+		for j := range found {
+			if found[j].URL == u {
+				match = &found[j]
+			}
+		}
+		if match == nil {
+			t.Errorf("Lookup(%q) = %+v; want entry for that URL", u, found)
+			continue
+		}
+
+		rec, closer, err := Fetch(dir, *match)
+		if err != nil {
+			t.Fatalf("Fetch(%+v) error = %v", *match, err)
+		}
+		payload, err := io.ReadAll(rec.Payload)
+		closer.Close()
+		if err != nil {
+			t.Fatalf("reading payload error = %v", err)
+		}
+		if want := fmt.Sprintf("<html>page %d</html>", i); string(payload) != want {
+			t.Errorf("Fetch(%q) payload = %q; want %q", u, payload, want)
+		}
+		if rec.Header.Get(warc.FieldTargetURI) != u {
+			t.Errorf("Fetch(%q) target = %q", u, rec.Header.Get(warc.FieldTargetURI))
+		}
+	}
+
+	if missing, err := idx.Lookup("https://missing.example.net/"); err != nil || len(missing) != 0 {
+		t.Errorf("Lookup(missing) = (%v, %v); want no entries", missing, err)
+	}
+}
+
+func TestParseLine_RoundTrip(t *testing.T) {
+	e := Entry{
+		Key:       "com,example)/",
+		Timestamp: "20250102030405",
+		URL:       "https://example.com/",
+		MIME:      "text/html",
+		Status:    "200",
+		Digest:    "ABC",
+		Filename:  "x.warc.gz",
+		Offset:    10,
+		Length:    20,
+	}
+	got, err := ParseLine(e.String())
+	if err != nil {
+		t.Fatalf("ParseLine error = %v", err)
+	}
+	if got != e {
+		t.Errorf("ParseLine(String()) = %+v; want %+v", got, e)
+	}
+
+	if _, err := ParseLine("garbage"); err == nil {
+		t.Error("ParseLine(garbage) error = nil; want error")
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	entries := []Entry{
+		{Key: "com,example)/copy", URL: "https://example.com/copy", Timestamp: "20250102000000", MIME: "warc/revisit", Digest: "AAA"},
+		{Key: "com,example)/", URL: "https://example.com/", Timestamp: "20250101000000", MIME: "text/html", Digest: "AAA"},
+		{Key: "com,example)/", URL: "https://example.com/", Timestamp: "20250103000000", MIME: "warc/revisit", Digest: "AAA"},
+		{Key: "com,example)/index.html", URL: "https://example.com/index.html", Timestamp: "20250104000000", MIME: "warc/revisit", Digest: "AAA"},
+		{Key: "com,example)/other", URL: "https://example.com/other", Timestamp: "20250101000000", MIME: "text/html", Digest: "BBB"},
+		{Key: "com,example)/other", URL: "https://example.com/other", Timestamp: "20250105000000", MIME: "warc/revisit", Digest: "BBB"},
+		{Key: "com,example)/nodigest", URL: "https://example.com/nodigest", Timestamp: "20250101000000", MIME: "text/html"},
+	}
+
+	var buf strings.Builder
+	if err := Write(&buf, entries); err != nil {
+		t.Fatalf("Write error = %v", err)
+	}
+	read, err := Read(strings.NewReader(buf.String()))
+	if err != nil || len(read) != len(entries) {
+		t.Fatalf("Read = (%d entries, %v); want %d", len(read), err, len(entries))
+	}
+
+	clusters := Duplicates(read)
+	if len(clusters) != 1 {
+		t.Fatalf("Duplicates = %+v; want one cluster", clusters)
+	}
+	c := clusters[0]
+	if c.Digest != "AAA" || c.Original.URL != "https://example.com/" || c.Original.MIME != "text/html" {
+		t.Errorf("cluster original = %s %+v; want the response for https://example.com/", c.Digest, c.Original)
+	}
+	want := []string{"https://example.com/", "https://example.com/copy", "https://example.com/index.html"}
+	if got := c.URLs(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("URLs() = %v; want %v", got, want)
+	}
+}
+
+func TestResolve_Revisit(t *testing.T) {
+	dir := t.TempDir()
+	original := newResponse("https://example.com/", "<html>shared</html>")
+	original.Header.Set(warc.FieldPayloadDigest, warc.Digest([]byte("<html>shared</html>")))
+	revisit := warc.NewRevisitRecord("https://example.com/copy", warc.ProfileIdenticalPayloadDigest, original)
+	revisit.HTTPHeader = original.HTTPHeader
+
+	fm, err := warc.NewFileManager(warc.FileManagerOptions{Dir: dir, Prefix: "test", Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	if _, err := fm.WriteRecords(original, revisit); err != nil {
+		t.Fatalf("WriteRecords error = %v", err)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	var entries []Entry
+	for _, f := range files {
+		e, err := IndexFile(f)
+		if err != nil {
+			t.Fatalf("IndexFile error = %v", err)
+		}
+		entries = append(entries, e...)
+	}
+	Sort(entries)
+	indexPath := filepath.Join(dir, "index.cdxj")
+	out, err := os.Create(indexPath)
+	if err != nil {
+		t.Fatalf("Create error = %v", err)
+	}
+	if err := Write(out, entries); err != nil {
+		t.Fatalf("Write error = %v", err)
+	}
+	out.Close()
+
+	idx, err := Open(indexPath)
+	if err != nil {
+		t.Fatalf("Open error = %v", err)
+	}
+	defer idx.Close()
+
+	found, err := idx.Lookup("https://example.com/copy")
+	if err != nil || len(found) != 1 || found[0].MIME != "warc/revisit" {
+		t.Fatalf("Lookup(copy) = (%+v, %v); want the revisit", found, err)
+	}
+	rec, closer, err := Resolve(idx, dir, found[0])
+	if err != nil {
+		t.Fatalf("Resolve error = %v", err)
+	}
+	defer closer.Close()
+	if rec.ID() != original.ID() {
+		t.Errorf("Resolve = %s; want the original %s", rec.ID(), original.ID())
+	}
+	if payload, _ := io.ReadAll(rec.Payload); string(payload) != "<html>shared</html>" {
+		t.Errorf("Resolve payload = %q; want the original's", payload)
+	}
+}
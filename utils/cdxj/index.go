@@ -0,0 +1,128 @@
+package cdxj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/surt"
+)
+
+type Index interface {
+	Lookup(rawURL string) ([]Entry, error)
+	Close() error
+}
+
+type index struct {
+	file *os.File
+	size int64
+}
+
+func Open(path string) (Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open error: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat error: %w", err)
+	}
+
+	return &index{
+		file: file,
+		size: info.Size(),
+	}, nil
+}
+
+func (self *index) Lookup(rawURL string) ([]Entry, error) {
+	key, err := surt.Surt(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	lo, hi := int64(0), self.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := self.lineAt(mid)
+		if err != nil {
+			return nil, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		if lineKey(line) < key {
+			lo = start + int64(len(line))
+		} else {
+			hi = mid
+		}
+	}
+
+	res := []Entry{}
+	start, _, err := self.lineAt(lo)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(io.NewSectionReader(self.file, start, self.size-start))
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			k := lineKey(line)
+			if k != key {
+				break
+			}
+			entry, errParse := ParseLine(line)
+			if errParse != nil {
+				return res, errParse
+			}
+			res = append(res, entry)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("read error: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+func (self *index) Close() error {
+	return self.file.Close()
+}
+
+func (self *index) lineAt(pos int64) (int64, string, error) {
+	start := pos
+	if pos > 0 {
+		start = pos - 1
+	}
+	r := bufio.NewReader(io.NewSectionReader(self.file, start, self.size-start))
+
+	if pos > 0 {
+		skipped, err := r.ReadString('\n')
+		if err == io.EOF {
+			return self.size, "", nil
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("read error: %w", err)
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", fmt.Errorf("read error: %w", err)
+	}
+
+	return start, line, nil
+}
+
+func lineKey(line string) string {
+	key, _, _ := strings.Cut(line, " ")
+
+	return strings.TrimRight(key, "\r\n")
+}
@@ -0,0 +1,33 @@
+package charset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+type Result struct {
+	Text     string
+	Encoding string
+	Certain  bool
+}
+
+// Decode converts an HTML body to UTF-8 following the WHATWG sniffing order:
+// byte order mark, then the Content-Type charset, then a prescan of the first
+// 1024 bytes for <meta charset>, then a UTF-8 check, falling back to
+// windows-1252. Certain is false when the encoding was guessed from the body.
+func Decode(body []byte, contentType string) (Result, error) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return Result{}, fmt.Errorf("decode %s error: %w", name, err)
+	}
+
+	return Result{
+		Text:     strings.TrimPrefix(string(text), "\uFEFF"),
+		Encoding: name,
+		Certain:  certain,
+	}, nil
+}
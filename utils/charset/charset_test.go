@@ -0,0 +1,100 @@
+package charset
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encode error = %v", err)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	const japaneseText = "<html><body>日本語のページ</body></html>"
+	const russianText = "<html><body>Привет, мир</body></html>"
+	const frenchText = "<html><body>Café crème</body></html>"
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+		encoding    string
+		certain     bool
+	}{
+		{
+			name:        "content type shift_jis",
+			body:        encode(t, japanese.ShiftJIS, japaneseText),
+			contentType: "text/html; charset=Shift_JIS",
+			want:        japaneseText,
+			encoding:    "shift_jis",
+			certain:     true,
+		},
+		{
+			name:        "meta charset windows-1251",
+			body:        encode(t, charmap.Windows1251, `<meta charset="windows-1251">`+russianText),
+			contentType: "text/html",
+			want:        `<meta charset="windows-1251">` + russianText,
+			encoding:    "windows-1251",
+		},
+		{
+			name:        "meta http-equiv iso-8859-1",
+			body:        encode(t, charmap.ISO8859_1, `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">`+frenchText),
+			contentType: "",
+			want:        `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">` + frenchText,
+			encoding:    "windows-1252",
+		},
+		{
+			name:        "bom wins over content type",
+			body:        append([]byte{0xEF, 0xBB, 0xBF}, russianText...),
+			contentType: "text/html; charset=windows-1251",
+			want:        russianText,
+			encoding:    "utf-8",
+			certain:     true,
+		},
+		{
+			name:        "utf-16 bom",
+			body:        encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), japaneseText),
+			contentType: "text/html",
+			want:        japaneseText,
+			encoding:    "utf-16le",
+			certain:     true,
+		},
+		{
+			name:        "undeclared utf-8",
+			body:        []byte(japaneseText),
+			contentType: "text/html",
+			want:        japaneseText,
+			encoding:    "utf-8",
+		},
+		{
+			name:        "undeclared fallback",
+			body:        encode(t, charmap.Windows1252, frenchText),
+			contentType: "text/html",
+			want:        frenchText,
+			encoding:    "windows-1252",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.body, tt.contentType)
+		if err != nil {
+			t.Fatalf("%s: Decode error = %v", tt.name, err)
+		}
+		if got.Text != tt.want {
+			t.Errorf("%s: Text = %q; want %q", tt.name, got.Text, tt.want)
+		}
+		if got.Encoding != tt.encoding || got.Certain != tt.certain {
+			t.Errorf("%s: encoding = (%q, %v); want (%q, %v)", tt.name, got.Encoding, got.Certain, tt.encoding, tt.certain)
+		}
+	}
+}
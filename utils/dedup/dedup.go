@@ -0,0 +1,300 @@
+package dedup
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	DefaultShingleSize = 3
+	DefaultBands       = 16
+	DefaultRows        = 4
+)
+
+// Options tune both detectors. Two texts are near-duplicates when their
+// SimHashes differ in at most MaxDistance bits or their MinHash signatures
+// estimate a Jaccard similarity of at least MinSimilarity. Bands and Rows set
+// the MinHash signature length and the LSH banding, which finds candidates
+// above roughly (1/Bands)^(1/Rows) similarity.
+type Options struct {
+	ShingleSize   int
+	MaxDistance   int
+	MinSimilarity float64
+	Bands         int
+	Rows          int
+}
+
+type Fingerprint struct {
+	SimHash uint64
+	MinHash []uint32
+}
+
+type Entry struct {
+	ID string
+	Fingerprint
+}
+
+type Match struct {
+	ID         string
+	Distance   int
+	Similarity float64
+}
+
+type Index interface {
+	Fingerprint(text string) (Fingerprint, bool)
+	Add(id string, fp Fingerprint) []Match
+	Query(fp Fingerprint) []Match
+	Groups() map[string][]string
+	Len() int
+	Snapshot() []Entry
+	Restore(entries []Entry)
+}
+
+type index struct {
+	mu      sync.Mutex
+	opts    Options
+	seeds   []uint64
+	entries []Entry
+	byID    map[string]int
+	parent  []int
+	tables  []simTable
+	bands   []map[uint64][]int
+}
+
+func NewIndex(opts Options) Index {
+	if opts.ShingleSize <= 0 {
+		opts.ShingleSize = DefaultShingleSize
+	}
+	if opts.Bands <= 0 {
+		opts.Bands = DefaultBands
+	}
+	if opts.Rows <= 0 {
+		opts.Rows = DefaultRows
+	}
+	opts.MaxDistance = min(max(opts.MaxDistance, 0), 63)
+
+	bands := make([]map[uint64][]int, opts.Bands)
+	for i := range bands {
+		bands[i] = make(map[uint64][]int)
+	}
+
+	return &index{
+		mu:      sync.Mutex{},
+		opts:    opts,
+		seeds:   minHashSeeds(opts.Bands * opts.Rows),
+		entries: []Entry{},
+		byID:    make(map[string]int),
+		parent:  []int{},
+		tables:  newSimTables(opts.MaxDistance),
+		bands:   bands,
+	}
+}
+
+// Shingles splits text into lowercase words and returns every run of size
+// consecutive words, or the whole text when it is shorter than that.
+func Shingles(text string, size int) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) <= size {
+		return []string{strings.Join(words, " ")}
+	}
+
+	res := make([]string, 0, len(words)-size+1)
+	for i := 0; i+size <= len(words); i++ {
+		res = append(res, strings.Join(words[i:i+size], " "))
+	}
+
+	return res
+}
+
+func hashShingles(shingles []string) []uint64 {
+	res := make([]uint64, len(shingles))
+	for i, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		res[i] = h.Sum64()
+	}
+
+	return res
+}
+
+// Fingerprint reports false for a text without words, which would otherwise
+// match every other empty page.
+func (self *index) Fingerprint(text string) (Fingerprint, bool) {
+	hashes := hashShingles(Shingles(text, self.opts.ShingleSize))
+	if len(hashes) == 0 {
+		return Fingerprint{}, false
+	}
+
+	return Fingerprint{
+		SimHash: SimHash(hashes),
+		MinHash: minHash(hashes, self.seeds),
+	}, true
+}
+
+// Add indexes fp under id and returns the near-duplicates added before it.
+// The id joins the group of every match, so groups are the connected
+// components of the near-duplicate relation. Adding an id twice keeps the
+// first fingerprint.
+func (self *index) Add(id string, fp Fingerprint) []Match {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if _, ok := self.byID[id]; ok {
+		return self.query(fp, id)
+	}
+	matches := self.query(fp, id)
+
+	n := len(self.entries)
+	self.entries = append(self.entries, Entry{ID: id, Fingerprint: fp})
+	self.byID[id] = n
+	self.parent = append(self.parent, n)
+	for i := range self.tables {
+		self.tables[i].add(fp.SimHash, n)
+	}
+	if len(fp.MinHash) == len(self.seeds) {
+		for band, key := range self.bandKeys(fp.MinHash) {
+			self.bands[band][key] = append(self.bands[band][key], n)
+		}
+	}
+	for _, m := range matches {
+		self.union(n, self.byID[m.ID])
+	}
+
+	return matches
+}
+
+func (self *index) Query(fp Fingerprint) []Match {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.query(fp, "")
+}
+
+// query returns the indexed entries other than skip that are near-duplicates
+// of fp, most similar first.
+func (self *index) query(fp Fingerprint, skip string) []Match {
+	candidates := make(map[int]struct{})
+	for i := range self.tables {
+		for _, n := range self.tables[i].candidates(fp.SimHash) {
+			candidates[n] = struct{}{}
+		}
+	}
+	if len(fp.MinHash) == len(self.seeds) {
+		for band, key := range self.bandKeys(fp.MinHash) {
+			for _, n := range self.bands[band][key] {
+				candidates[n] = struct{}{}
+			}
+		}
+	}
+
+	res := []Match{}
+	order := make(map[string]int, len(candidates))
+	for n := range candidates {
+		entry := self.entries[n]
+		if entry.ID == skip {
+			continue
+		}
+		m := Match{
+			ID:         entry.ID,
+			Distance:   Distance(fp.SimHash, entry.SimHash),
+			Similarity: Similarity(fp.MinHash, entry.MinHash),
+		}
+		if m.Distance <= self.opts.MaxDistance || m.Similarity >= self.opts.MinSimilarity {
+			res = append(res, m)
+			order[m.ID] = n
+		}
+	}
+	slices.SortFunc(res, func(a, b Match) int {
+		if c := cmp.Compare(b.Similarity, a.Similarity); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(order[a.ID], order[b.ID])
+	})
+
+	return res
+}
+
+func (self *index) find(n int) int {
+	for self.parent[n] != n {
+		self.parent[n] = self.parent[self.parent[n]]
+		n = self.parent[n]
+	}
+
+	return n
+}
+
+// union keeps the earlier entry as the root, so a group is named after the
+// first page of it that was indexed.
+func (self *index) union(a, b int) {
+	ra, rb := self.find(a), self.find(b)
+	if ra == rb {
+		return
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	self.parent[rb] = ra
+}
+
+// Groups returns the groups with more than one member, keyed by their first
+// id, with members in the order they were indexed.
+func (self *index) Groups() map[string][]string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	members := make(map[int][]string)
+	for n, entry := range self.entries {
+		root := self.find(n)
+		members[root] = append(members[root], entry.ID)
+	}
+
+	res := make(map[string][]string)
+	for root, ids := range members {
+		if len(ids) > 1 {
+			res[self.entries[root].ID] = ids
+		}
+	}
+
+	return res
+}
+
+func (self *index) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.entries)
+}
+
+func (self *index) Snapshot() []Entry {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make([]Entry, len(self.entries))
+	for i, entry := range self.entries {
+		res[i] = Entry{ID: entry.ID, Fingerprint: Fingerprint{
+			SimHash: entry.SimHash,
+			MinHash: slices.Clone(entry.MinHash),
+		}}
+	}
+
+	return res
+}
+
+// Restore adds entries in order, which rebuilds the groups they had.
+func (self *index) Restore(entries []Entry) {
+	for _, entry := range entries {
+		self.Add(entry.ID, entry.Fingerprint)
+	}
+}
@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func article(r *rand.Rand, words int) []string {
+	vocabulary := strings.Fields("the crawler fetches pages from the web and stores them in warc files " +
+		"while an index maps every term to the documents containing it so that queries " +
+		"can be answered quickly ranking combines link analysis with text relevance signals")
+	res := make([]string, words)
+	for i := range res {
+		res[i] = vocabulary[r.IntN(len(vocabulary))] + fmt.Sprint(r.IntN(50))
+	}
+	return res
+}
+
+func TestShingles(t *testing.T) {
+	got := Shingles("Hello, World! Go go-search", 2)
+	want := []string{"hello world", "world go", "go go", "go search"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Shingles = %q; want %q", got, want)
+	}
+	if got := Shingles("one two", 3); len(got) != 1 || got[0] != "one two" {
+		t.Errorf("Shingles(short) = %q; want the whole text", got)
+	}
+	if got := Shingles(" ... ", 3); got != nil {
+		t.Errorf("Shingles(no words) = %q; want nil", got)
+	}
+}
+
+func TestSimTables(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	tables := newSimTables(3)
+
+	fps := make([]uint64, 1000)
+	for i := range fps {
+		fps[i] = r.Uint64()
+		for j := range tables {
+			tables[j].add(fps[i], i)
+		}
+	}
+
+	for i, fp := range fps[:100] {
+		query := fp
+		for range r.IntN(4) {
+			query ^= 1 << r.IntN(64)
+		}
+		found := false
+		for j := range tables {
+			for _, n := range tables[j].candidates(query) {
+				found = found || n == i
+			}
+		}
+		if !found {
+			t.Fatalf("fingerprint %d within %d bits not among candidates", i, Distance(fp, query))
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	seeds := minHashSeeds(256)
+
+	a := make([]uint64, 200)
+	for i := range a {
+		a[i] = r.Uint64()
+	}
+	// b shares 150 of 250 distinct shingles with a: Jaccard 0.6.
+	b := append(append([]uint64{}, a[:150]...), make([]uint64, 50)...)
+	for i := 150; i < len(b); i++ {
+		b[i] = r.Uint64()
+	}
+
+	got := Similarity(minHash(a, seeds), minHash(b, seeds))
+	if math.Abs(got-0.6) > 0.08 {
+		t.Errorf("Similarity = %v; want about 0.6", got)
+	}
+	if got := Similarity(minHash(a, seeds), minHash(a, seeds)); got != 1 {
+		t.Errorf("Similarity(same) = %v; want 1", got)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	idx := NewIndex(Options{MaxDistance: 3, MinSimilarity: 0.7})
+
+	base := article(r, 400)
+	edited := append([]string{}, base...)
+	edited[100] = "changed"
+	edited[300] = "timestamp"
+	boilerplate := append(append([]string{}, base...), "footer", "links")
+	other := article(r, 400)
+
+	texts := map[string]string{
+		"a": strings.Join(base, " "),
+		"b": strings.Join(other, " "),
+		"c": strings.Join(edited, " "),
+		"d": strings.Join(boilerplate, " "),
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		fp, ok := idx.Fingerprint(texts[id])
+		if !ok {
+			t.Fatalf("Fingerprint(%s) not ok", id)
+		}
+		matches := idx.Add(id, fp)
+		switch id {
+		case "a", "b":
+			if len(matches) != 0 {
+				t.Errorf("Add(%s) matches = %+v; want none", id, matches)
+			}
+		default:
+			if len(matches) == 0 || matches[0].ID != "a" {
+				t.Errorf("Add(%s) matches = %+v; want a first", id, matches)
+			}
+		}
+	}
+
+	if _, ok := idx.Fingerprint("  !! "); ok {
+		t.Error("Fingerprint(no words) ok = true; want false")
+	}
+	groups := idx.Groups()
+	if len(groups) != 1 || strings.Join(groups["a"], " ") != "a c d" {
+		t.Errorf("Groups = %v; want a: [a c d]", groups)
+	}
+
+	restored := NewIndex(Options{MaxDistance: 3, MinSimilarity: 0.7})
+	restored.Restore(idx.Snapshot())
+	if groups := restored.Groups(); restored.Len() != 4 || strings.Join(groups["a"], " ") != "a c d" {
+		t.Errorf("restored Len = %d, Groups = %v; want 4, a: [a c d]", restored.Len(), groups)
+	}
+	fp, _ := restored.Fingerprint(texts["c"])
+	if matches := restored.Query(fp); len(matches) < 2 {
+		t.Errorf("restored Query(c) = %+v; want a, c and d", matches)
+	}
+}
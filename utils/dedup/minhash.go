@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"math"
+)
+
+// mix is the splitmix64 finalizer, used to derive independent hash functions
+// from one shingle hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// minHashSeeds is fixed, so that signatures stay comparable across runs.
+func minHashSeeds(n int) []uint64 {
+	res := make([]uint64, n)
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range res {
+		state += 0x9e3779b97f4a7c15
+		res[i] = mix(state)
+	}
+
+	return res
+}
+
+// minHash keeps, for each seeded hash function, the smallest value over the
+// shingles. Two signatures agree in a position with probability equal to the
+// Jaccard similarity of the shingle sets.
+func minHash(hashes []uint64, seeds []uint64) []uint32 {
+	res := make([]uint32, len(seeds))
+	for i, seed := range seeds {
+		lowest := uint32(math.MaxUint32)
+		for _, h := range hashes {
+			lowest = min(lowest, uint32(mix(h^seed)>>32))
+		}
+		res[i] = lowest
+	}
+
+	return res
+}
+
+// Similarity estimates the Jaccard similarity of the texts behind two MinHash
+// signatures, or returns 0 when their lengths differ.
+func Similarity(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+
+	return float64(same) / float64(len(a))
+}
+
+// bandKeys hashes each band of Rows signature values into one LSH bucket key;
+// texts sharing any bucket become candidates.
+func (self *index) bandKeys(signature []uint32) []uint64 {
+	res := make([]uint64, self.opts.Bands)
+	for band := range res {
+		key := uint64(band)
+		for _, v := range signature[band*self.opts.Rows : (band+1)*self.opts.Rows] {
+			key = mix(key ^ uint64(v))
+		}
+		res[band] = key
+	}
+
+	return res
+}
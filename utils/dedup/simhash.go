@@ -0,0 +1,77 @@
+package dedup
+
+import (
+	"math/bits"
+)
+
+// SimHash combines feature hashes into a 64-bit fingerprint in which similar
+// feature sets differ in few bits (Charikar). Repeated features weigh more.
+func SimHash(hashes []uint64) uint64 {
+	var weights [64]int
+	for _, h := range hashes {
+		for bit := range 64 {
+			if h&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var res uint64
+	for bit, w := range weights {
+		if w > 0 {
+			res |= 1 << bit
+		}
+	}
+
+	return res
+}
+
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// simTable is one of the permuted tables of Manku, Jain and Das Sarma,
+// "Detecting near-duplicates for web crawling". Fingerprints within k bits of
+// each other agree exactly on at least one of k+1 blocks, so each table
+// rotates its own block to the front and buckets fingerprints by that prefix;
+// a query then only compares against the fingerprints sharing one.
+type simTable struct {
+	rotate  int
+	width   int
+	buckets map[uint64][]int
+}
+
+func newSimTables(maxDistance int) []simTable {
+	blocks := maxDistance + 1
+	res := make([]simTable, 0, blocks)
+	offset := 0
+	for i := range blocks {
+		width := 64 / blocks
+		if i < 64%blocks {
+			width++
+		}
+		res = append(res, simTable{
+			rotate:  offset,
+			width:   width,
+			buckets: make(map[uint64][]int),
+		})
+		offset += width
+	}
+
+	return res
+}
+
+func (self *simTable) prefix(fp uint64) uint64 {
+	return bits.RotateLeft64(fp, self.rotate) >> (64 - self.width)
+}
+
+func (self *simTable) add(fp uint64, n int) {
+	key := self.prefix(fp)
+	self.buckets[key] = append(self.buckets[key], n)
+}
+
+func (self *simTable) candidates(fp uint64) []int {
+	return self.buckets[self.prefix(fp)]
+}
@@ -0,0 +1,309 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// skippedTags never hold content: they are either not rendered or are page
+// furniture by definition.
+var skippedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"canvas": true, "iframe": true, "object": true, "embed": true, "head": true,
+	"nav": true, "aside": true, "footer": true, "form": true, "button": true,
+	"select": true, "input": true, "textarea": true, "dialog": true, "menu": true,
+}
+
+// blockTags end a paragraph of the extracted text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"pre": true, "blockquote": true, "table": true, "tr": true, "td": true, "th": true,
+	"figure": true, "figcaption": true, "header": true, "address": true, "hr": true, "br": true,
+	"body": true,
+}
+
+// scoredTags hold the text whose length and punctuation vote for the
+// container around them, as in Arc90's Readability.
+var scoredTags = map[string]bool{
+	"p": true, "pre": true, "td": true, "blockquote": true,
+}
+
+var (
+	negativePattern = regexp.MustCompile(`(?i)\b(ad|ads|advert\w*|banner|breadcrumbs?|combx|comments?|com-|contact|cookie\w*|disqus|extra|foot(er|note)?|header|hidden|legends?|masthead|media|menu|meta|modal|nav\w*|outbrain|popup|promo\w*|related|remark|rss|scroll|share|shoutbox|sidebar|skyscraper|social|sponsor\w*|shopping|subscribe|tags?|taboola|tool|widget)\b`)
+	positivePattern = regexp.MustCompile(`(?i)\b(article|body|blog|content|entry|h-entry|hentry|main|page|pagination|post|story|text)\b`)
+)
+
+type content struct {
+	opts      Options
+	body      *html.Node
+	scores    map[*html.Node]float64
+	headings  []Heading
+	firstTime time.Time
+}
+
+func newContent(opts Options, body *html.Node) *content {
+	c := &content{
+		opts:   opts,
+		body:   body,
+		scores: make(map[*html.Node]float64),
+	}
+	c.score(body)
+
+	return c
+}
+
+// unlikely reports nodes that are boilerplate by their tag, visibility or
+// class and id names.
+func unlikely(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if skippedTags[n.Data] {
+		return true
+	}
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if style, _ := attr(n, "style"); strings.Contains(strings.ReplaceAll(style, " ", ""), "display:none") {
+		return true
+	}
+	if v, _ := attr(n, "role"); v == "navigation" || v == "banner" || v == "contentinfo" || v == "complementary" {
+		return true
+	}
+	switch n.Data {
+	case "body", "article", "main":
+		return false
+	}
+	names := classAndID(n)
+
+	return negativePattern.MatchString(names) && !positivePattern.MatchString(names)
+}
+
+func classAndID(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+
+	return strings.ReplaceAll(class+" "+id, "_", "-")
+}
+
+// score walks the content, skipping boilerplate, and credits each scored
+// block to its parent in full, its grandparent by half and further ancestors
+// less. A div holding no other blocks counts as a paragraph.
+func (self *content) score(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || unlikely(c) {
+			continue
+		}
+
+		if level := headingLevel(c.Data); level > 0 {
+			if text := textContent(c); text != "" {
+				self.headings = append(self.headings, Heading{Level: level, Text: text})
+			}
+		}
+		if c.Data == "time" && self.firstTime.IsZero() {
+			if datetime, ok := attr(c, "datetime"); ok {
+				self.firstTime, _ = parseTime(datetime)
+			}
+		}
+
+		if scoredTags[c.Data] || (c.Data == "div" && !hasBlockChild(c)) {
+			text := textContent(c)
+			if length := utf8.RuneCountInString(text); length >= self.opts.MinBlockLength {
+				points := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+				ancestor := c.Parent
+				for level := 0; ancestor != nil && level < 5; level++ {
+					if _, ok := self.scores[ancestor]; !ok {
+						self.scores[ancestor] = initialScore(ancestor)
+					}
+					divider := 1.0
+					switch {
+					case level == 1:
+						divider = 2
+					case level > 1:
+						divider = float64(level * 3)
+					}
+					self.scores[ancestor] += points / divider
+					if ancestor == self.body {
+						break
+					}
+					ancestor = ancestor.Parent
+				}
+			}
+		}
+
+		self.score(c)
+	}
+}
+
+func initialScore(n *html.Node) float64 {
+	res := 0.0
+	switch n.Data {
+	case "article", "main":
+		res = 10
+	case "div", "section":
+		res = 5
+	case "pre", "td", "blockquote":
+		res = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li":
+		res = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		res = -5
+	}
+	names := classAndID(n)
+	if negativePattern.MatchString(names) {
+		res -= 25
+	}
+	if positivePattern.MatchString(names) {
+		res += 25
+	}
+
+	return res
+}
+
+func headingLevel(tag string) int {
+	if len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6' {
+		return int(tag[1] - '0')
+	}
+
+	return 0
+}
+
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] && c.Data != "br" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// linkDensity is the share of n's text inside links.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(textContent(n))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += utf8.RuneCountInString(textContent(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return float64(linked) / float64(total)
+}
+
+// main picks the highest scoring container, its score discounted by its link
+// density, together with the siblings that score close to it or read like
+// paragraphs of the same text. Candidates are visited in document order, so a
+// tie goes to the first of them.
+func (self *content) main() []*html.Node {
+	var top *html.Node
+	topScore := 0.0
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if score, ok := self.scores[n]; ok {
+			score *= 1 - linkDensity(n)
+			if top == nil || score > topScore {
+				top, topScore = n, score
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(self.body)
+	if top == nil || top == self.body || top.Parent == nil {
+		return []*html.Node{self.body}
+	}
+
+	threshold := max(10, topScore*0.2)
+	res := []*html.Node{}
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s == top {
+			res = append(res, s)
+			continue
+		}
+		if s.Type != html.ElementNode || unlikely(s) {
+			continue
+		}
+		if score, ok := self.scores[s]; ok && score*(1-linkDensity(s)) >= threshold {
+			res = append(res, s)
+			continue
+		}
+		if s.Data == "p" {
+			text := textContent(s)
+			density := linkDensity(s)
+			length := utf8.RuneCountInString(text)
+			if (length > 80 && density < 0.25) || (length > 0 && density == 0 && strings.HasSuffix(text, ".")) {
+				res = append(res, s)
+			}
+		}
+	}
+
+	return res
+}
+
+// text renders nodes as paragraphs, dropping boilerplate and blocks that are
+// mostly links.
+func (self *content) text(nodes []*html.Node) string {
+	paragraphs := []string{}
+	var line strings.Builder
+	flush := func() {
+		if text := collapseSpace(line.String()); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+		line.Reset()
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if unlikely(n) {
+				return
+			}
+			if blockTags[n.Data] && n.Data != "br" && n.Data != "hr" && headingLevel(n.Data) == 0 && !hasBlockChild(n) {
+				text := textContent(n)
+				if linkDensity(n) > self.opts.MaxLinkDensity && utf8.RuneCountInString(text) < 3*self.opts.MinBlockLength {
+					return
+				}
+			}
+			if blockTags[n.Data] {
+				flush()
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && blockTags[n.Data] {
+			flush()
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	flush()
+
+	return strings.Join(paragraphs, "\n\n")
+}
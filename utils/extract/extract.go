@@ -0,0 +1,151 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// Document is what a page says, without its navigation and page furniture.
+// Text holds the main content as paragraphs separated by blank lines.
+type Document struct {
+	Title         string    `json:"title,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Headings      []Heading `json:"headings,omitempty"`
+	Text          string    `json:"text"`
+	LanguageHints []string  `json:"language_hints,omitempty"`
+	Published     time.Time `json:"published,omitzero"`
+	Modified      time.Time `json:"modified,omitzero"`
+}
+
+// Options tune the boilerplate removal. A text block counts towards a
+// container's score from MinBlockLength characters, and blocks in the result
+// made mostly of link text, above MaxLinkDensity, are dropped.
+type Options struct {
+	MinBlockLength int
+	MaxLinkDensity float64
+}
+
+const (
+	DefaultMinBlockLength = 25
+	DefaultMaxLinkDensity = 0.5
+)
+
+type Extractor interface {
+	Extract(r io.Reader) (Document, error)
+	ExtractNode(root *html.Node) Document
+}
+
+type extractor struct {
+	opts Options
+}
+
+func NewExtractor(opts Options) Extractor {
+	if opts.MinBlockLength <= 0 {
+		opts.MinBlockLength = DefaultMinBlockLength
+	}
+	if opts.MaxLinkDensity <= 0 {
+		opts.MaxLinkDensity = DefaultMaxLinkDensity
+	}
+
+	return &extractor{
+		opts: opts,
+	}
+}
+
+func (self *extractor) Extract(r io.Reader) (Document, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return Document{}, fmt.Errorf("parse error: %w", err)
+	}
+
+	return self.ExtractNode(root), nil
+}
+
+func (self *extractor) ExtractNode(root *html.Node) Document {
+	doc := Document{}
+	if root == nil {
+		return doc
+	}
+
+	meta := readMeta(root)
+	doc.Title = meta.title
+	doc.Description = meta.description
+	doc.LanguageHints = meta.languages
+	doc.Published = meta.published
+	doc.Modified = meta.modified
+
+	body := find(root, "body")
+	if body == nil {
+		body = root
+	}
+	c := newContent(self.opts, body)
+	doc.Headings = c.headings
+	doc.Text = c.text(c.main())
+	if doc.Title == "" && len(doc.Headings) > 0 {
+		doc.Title = doc.Headings[0].Text
+	}
+	if doc.Published.IsZero() {
+		doc.Published = c.firstTime
+	}
+
+	return doc
+}
+
+func find(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, tag); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		case html.ElementNode:
+			if skippedTags[n.Data] {
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return collapseSpace(sb.String())
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,134 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html lang="en-GB">
+<head>
+<title>Rivers of Europe | The Atlas</title>
+<meta name="description" content="How the great rivers shaped the continent.">
+<meta property="article:modified_time" content="2024-03-05T10:00:00Z">
+<meta property="og:locale" content="en_GB">
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[{"@type":"WebSite","name":"The Atlas"},{"@type":"NewsArticle","headline":"Rivers of Europe","datePublished":"2024-03-01T08:30:00+01:00"}]}</script>
+<style>body { font-family: serif; }</style>
+</head>
+<body>
+<header class="site-header"><a href="/">The Atlas</a></header>
+<nav><ul><li><a href="/a">Home</a></li><li><a href="/b">World</a></li><li><a href="/c">Science</a></li></ul></nav>
+<div class="layout">
+  <div id="sidebar" class="sidebar">
+    <h3>Popular</h3>
+    <ul><li><a href="/x">Ten facts about mountains you did not know, number seven will surprise you</a></li></ul>
+  </div>
+  <article class="post">
+    <h1>Rivers of Europe</h1>
+    <p class="byline">By A. Writer, <time datetime="2024-03-01">1 March 2024</time></p>
+    <p>The Danube flows through ten countries, more than any other river in the world, and has carried trade, armies and ideas between east and west for millennia.</p>
+    <h2>The Rhine</h2>
+    <p>The Rhine rises in the Swiss Alps and reaches the North Sea in the Netherlands, passing through some of the most industrial regions of the continent, with barges, locks and ports along its length.</p>
+    <p>Its valley, with castles, vineyards and steep slopes, is a World Heritage Site, and its legends, such as that of the Lorelei, are known far beyond Germany.</p>
+    <div class="share-buttons"><a href="/share">Share on social media</a></div>
+  </article>
+</div>
+<div class="comments"><p>Great article, thanks a lot, I learned so much from it, really!</p></div>
+<footer><p>Copyright 2024 The Atlas, all rights reserved, and a long footer sentence too.</p></footer>
+<script>trackPageView();</script>
+</body>
+</html>`
+
+func TestExtract(t *testing.T) {
+	doc, err := NewExtractor(Options{}).Extract(strings.NewReader(articlePage))
+	if err != nil {
+		t.Fatalf("Extract error = %v", err)
+	}
+
+	if doc.Title != "Rivers of Europe | The Atlas" {
+		t.Errorf("Title = %q", doc.Title)
+	}
+	if doc.Description != "How the great rivers shaped the continent." {
+		t.Errorf("Description = %q", doc.Description)
+	}
+	if strings.Join(doc.LanguageHints, " ") != "en-GB" {
+		t.Errorf("LanguageHints = %q; want [en-GB]", doc.LanguageHints)
+	}
+	if want := time.Date(2024, 3, 1, 7, 30, 0, 0, time.UTC); !doc.Published.Equal(want) {
+		t.Errorf("Published = %v; want %v from JSON-LD", doc.Published, want)
+	}
+	if want := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC); !doc.Modified.Equal(want) {
+		t.Errorf("Modified = %v; want %v", doc.Modified, want)
+	}
+
+	headings := []string{}
+	for _, h := range doc.Headings {
+		headings = append(headings, h.Text)
+	}
+	if strings.Join(headings, "|") != "Rivers of Europe|The Rhine" {
+		t.Errorf("Headings = %+v; want the article's h1 and h2", doc.Headings)
+	}
+
+	for _, want := range []string{"The Danube flows", "The Rhine rises", "Lorelei", "The Rhine\n\n"} {
+		if !strings.Contains(doc.Text, want) {
+			t.Errorf("Text lacks %q:\n%s", want, doc.Text)
+		}
+	}
+	for _, unwanted := range []string{"Home", "mountains", "Share on social", "Great article", "Copyright", "trackPageView", "font-family"} {
+		if strings.Contains(doc.Text, unwanted) {
+			t.Errorf("Text contains boilerplate %q:\n%s", unwanted, doc.Text)
+		}
+	}
+}
+
+func TestExtract_Fallbacks(t *testing.T) {
+	page := `<html><head><meta http-equiv="Content-Language" content="de, en">
+<meta name="date" content="2023-11-02"></head>
+<body><h1>Kurz</h1><div>Nur ein kurzer Satz ohne viel Inhalt hier.</div></body></html>`
+
+	doc, err := NewExtractor(Options{}).Extract(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Extract error = %v", err)
+	}
+	if doc.Title != "Kurz" {
+		t.Errorf("Title = %q; want the first heading", doc.Title)
+	}
+	if strings.Join(doc.LanguageHints, " ") != "de en" {
+		t.Errorf("LanguageHints = %q; want [de en]", doc.LanguageHints)
+	}
+	if want := time.Date(2023, 11, 2, 0, 0, 0, 0, time.UTC); !doc.Published.Equal(want) {
+		t.Errorf("Published = %v; want %v", doc.Published, want)
+	}
+	if doc.Text != "Kurz\n\nNur ein kurzer Satz ohne viel Inhalt hier." {
+		t.Errorf("Text = %q; want the whole body", doc.Text)
+	}
+}
+
+func TestExtract_TiedBlocks(t *testing.T) {
+	page := `<html><body>
+<section><article><p>First block of text, with commas, long enough to be scored as content here.</p></article></section>
+<section><article><p>Other block of text, with commas, long enough to be scored as content here.</p></article></section>
+</body></html>`
+
+	for range 50 {
+		doc, err := NewExtractor(Options{}).Extract(strings.NewReader(page))
+		if err != nil {
+			t.Fatalf("Extract error = %v", err)
+		}
+		if !strings.HasPrefix(doc.Text, "First block") || strings.Contains(doc.Text, "Other block") {
+			t.Fatalf("Text = %q; want the first of the equally scored blocks", doc.Text)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for _, s := range []string{"2024-03-01", "2024-03-01T08:30:00Z", "2024-03-01T08:30:00+0100", "Fri, 01 Mar 2024 08:30:00 GMT", "March 1, 2024"} {
+		if _, ok := parseTime(s); !ok {
+			t.Errorf("parseTime(%q) failed", s)
+		}
+	}
+	if _, ok := parseTime("yesterday"); ok {
+		t.Error("parseTime(yesterday) ok = true")
+	}
+}
@@ -0,0 +1,202 @@
+package extract
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type pageMeta struct {
+	title       string
+	description string
+	languages   []string
+	published   time.Time
+	modified    time.Time
+}
+
+var (
+	publishedNames = []string{
+		"article:published_time", "og:published_time", "datepublished", "datecreated",
+		"pubdate", "publishdate", "publish-date", "date", "dc.date", "dc.date.issued",
+		"dcterms.created", "dcterms.issued", "sailthru.date", "parsely-pub-date",
+	}
+	modifiedNames = []string{
+		"article:modified_time", "og:updated_time", "datemodified", "dc.date.modified",
+		"dcterms.modified", "last-modified",
+	}
+	descriptionNames = []string{"description", "og:description", "twitter:description", "dc.description"}
+	titleNames       = []string{"og:title", "twitter:title", "dc.title", "headline"}
+)
+
+// readMeta collects the page's metadata from <head> and from microdata and
+// JSON-LD anywhere in the document. The first of several candidate sources in
+// each of the lists above wins.
+func readMeta(root *html.Node) pageMeta {
+	res := pageMeta{}
+	values := make(map[string]string)
+	set := func(name, value string) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = collapseSpace(value)
+		if name == "" || value == "" {
+			return
+		}
+		if _, ok := values[name]; !ok {
+			values[name] = value
+		}
+	}
+	addLanguage := func(value string) {
+		for _, lang := range strings.Split(value, ",") {
+			lang = strings.ReplaceAll(strings.TrimSpace(lang), "_", "-")
+			if lang != "" && !slices.ContainsFunc(res.languages, func(l string) bool { return strings.EqualFold(l, lang) }) {
+				res.languages = append(res.languages, lang)
+			}
+		}
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html":
+				if lang, ok := attr(n, "lang"); ok {
+					addLanguage(lang)
+				} else if lang, ok := attr(n, "xml:lang"); ok {
+					addLanguage(lang)
+				}
+			case "title":
+				if res.title == "" {
+					res.title = textContent(n)
+				}
+			case "meta":
+				content, _ := attr(n, "content")
+				for _, key := range []string{"name", "property", "itemprop", "http-equiv"} {
+					if name, ok := attr(n, key); ok {
+						set(name, content)
+					}
+				}
+			case "script":
+				if typ, _ := attr(n, "type"); strings.EqualFold(strings.TrimSpace(typ), "application/ld+json") && n.FirstChild != nil {
+					readJSONLD(n.FirstChild.Data, set)
+				}
+			case "time":
+				if datetime, ok := attr(n, "datetime"); ok {
+					if _, pubdate := attr(n, "pubdate"); pubdate {
+						set("pubdate", datetime)
+					}
+				}
+			}
+			if prop, ok := attr(n, "itemprop"); ok && n.Data != "meta" {
+				value, ok := attr(n, "datetime")
+				if !ok {
+					value, ok = attr(n, "content")
+				}
+				if !ok {
+					value = textContent(n)
+				}
+				set(prop, value)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	first := func(names []string) string {
+		for _, name := range names {
+			if v := values[name]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	firstTime := func(names []string) time.Time {
+		for _, name := range names {
+			if t, ok := parseTime(values[name]); ok {
+				return t
+			}
+		}
+		return time.Time{}
+	}
+
+	if res.title == "" {
+		res.title = first(titleNames)
+	}
+	res.description = first(descriptionNames)
+	res.published = firstTime(publishedNames)
+	res.modified = firstTime(modifiedNames)
+	for _, name := range []string{"content-language", "language", "dc.language", "og:locale", "inlanguage"} {
+		if v := values[name]; v != "" {
+			addLanguage(v)
+		}
+	}
+
+	return res
+}
+
+// readJSONLD passes the schema.org properties the extractor uses to set,
+// searching nested objects and @graph arrays.
+func readJSONLD(data string, set func(name, value string)) {
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return
+	}
+
+	var walk func(any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			for _, key := range []string{"datePublished", "dateCreated", "dateModified", "headline", "description", "inLanguage"} {
+				if s, ok := v[key].(string); ok {
+					set(key, s)
+				}
+			}
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walk(v[key])
+			}
+		}
+	}
+	walk(v)
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"January 2, 2006",
+	"2 January 2006",
+}
+
+func parseTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
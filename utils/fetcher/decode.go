@@ -0,0 +1,91 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decode undoes the Content-Encoding codings of body, which are listed in the
+// order they were applied. At most limit bytes of output are kept, so a small
+// compressed body cannot expand without bound. A body cut short, as truncated
+// captures are, yields whatever decoded cleanly before the cut.
+func Decode(contentEncoding string, body []byte, limit int64) ([]byte, error) {
+	return DecodeReader(contentEncoding, bytes.NewReader(body), limit)
+}
+
+// DecodeReader is Decode for a body that is not in memory, such as one
+// spooled to disk.
+func DecodeReader(contentEncoding string, body io.Reader, limit int64) ([]byte, error) {
+	codings := []string{}
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+
+	r := body
+	for _, coding := range slices.Backward(codings) {
+		next, err := decoder(coding, r)
+		if err != nil {
+			return nil, err
+		}
+		if closer, ok := next.(io.Closer); ok {
+			defer closer.Close()
+		}
+		r = next
+	}
+
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, io.LimitReader(r, limit))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && buf.Len() == 0 {
+		return nil, fmt.Errorf("decode %s error: %w", contentEncoding, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decoder(coding string, r io.Reader) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip error: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// Some servers send raw DEFLATE instead of the zlib stream the
+		// spec asks for.
+		br := bufio.NewReader(r)
+		head, _ := br.Peek(2)
+		if len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("deflate error: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd error: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported content encoding %q", coding)
+}
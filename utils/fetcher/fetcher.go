@@ -0,0 +1,203 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+const acceptEncoding = "gzip, br, zstd"
+
+type Options struct {
+	UserAgent string
+	// From is sent as the From header so site operators can reach the crawl
+	// operator; it is omitted when empty.
+	From string
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	TLSConfig           *tls.Config
+	// DialContext replaces the default dialer, e.g. with a caching resolver.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+type Response struct {
+	*http.Response
+	RemoteAddr string
+	// RawRequestHeader and RawResponseHeader are the header blocks as they
+	// were sent and received, request and status lines included. They are
+	// nil when the exchange could not be recorded, as over HTTP/2, whose
+	// headers are compressed, or through a proxy tunnel.
+	RawRequestHeader  []byte
+	RawResponseHeader []byte
+}
+
+type Fetcher interface {
+	Fetch(ctx context.Context, req *http.Request) (*Response, error)
+	Client(timeout time.Duration) *http.Client
+}
+
+type fetcher struct {
+	transport http.RoundTripper
+	client    *http.Client
+}
+
+// headerTransport stamps every request leaving the shared transport with the
+// crawler's identity, whichever client sent it.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	from      string
+}
+
+func (self *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if self.userAgent != "" {
+		req.Header.Set("User-Agent", self.userAgent)
+	}
+	if self.from != "" {
+		req.Header.Set("From", self.from)
+	}
+
+	return self.base.RoundTrip(req)
+}
+
+func NewFetcher(opts Options) Fetcher {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := opts.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	tlsConfig := opts.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	transport := &headerTransport{
+		base: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         recordingDial(dial),
+			DialTLSContext:      recordingDialTLS(dial, tlsConfig, opts.TLSHandshakeTimeout),
+			TLSClientConfig:     opts.TLSConfig,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        opts.MaxIdleConns,
+			MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     opts.MaxConnsPerHost,
+			IdleConnTimeout:     opts.IdleConnTimeout,
+			TLSHandshakeTimeout: opts.TLSHandshakeTimeout,
+		},
+		userAgent: opts.UserAgent,
+		from:      opts.From,
+	}
+
+	return &fetcher{
+		transport: transport,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Fetch sends req without following redirects. It negotiates compression
+// itself, so the response body is left exactly as it came off the wire (as a
+// WARC record needs it) and callers decode it with Decode. The raw header
+// blocks are kept alongside for the same reason. Timeouts are up to ctx.
+func (self *fetcher) Fetch(ctx context.Context, req *http.Request) (*Response, error) {
+	res := &Response{}
+	var recorder *recordingConn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			res.RemoteAddr = info.Conn.RemoteAddr().String()
+			recorder, _ = info.Conn.(*recordingConn)
+			if recorder != nil {
+				recorder.start()
+			}
+		},
+	}
+	req = req.Clone(httptrace.WithClientTrace(ctx, trace))
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch error: %w", err)
+	}
+	res.Response = resp
+	if recorder != nil {
+		res.RawRequestHeader, res.RawResponseHeader = recorder.headers()
+	}
+
+	return res, nil
+}
+
+// recordingDial wraps plain connections so Fetch can record their headers.
+func recordingDial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		return newRecordingConn(conn), nil
+	}
+}
+
+// recordingDialTLS does the TLS handshake the transport would otherwise do, so
+// the plaintext of HTTP/1.x over TLS can be recorded. Connections that
+// negotiate HTTP/2 are handed back as they are for the transport to run h2
+// over them.
+func recordingDialTLS(dial func(ctx context.Context, network, address string) (net.Conn, error), config *tls.Config, timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		config := config.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		if len(config.NextProtos) == 0 {
+			config.NextProtos = []string{"h2", "http/1.1"}
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+			return tlsConn, nil
+		}
+
+		return newRecordingConn(tlsConn), nil
+	}
+}
+
+// Client returns a client on the shared transport for callers that want plain
+// net/http behaviour: redirects are followed and gzip is decoded
+// transparently.
+func (self *fetcher) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: self.transport,
+		Timeout:   timeout,
+	}
+}
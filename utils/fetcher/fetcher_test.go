@@ -0,0 +1,292 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const page = "<html><body>hello, compressed world</body></html>"
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("zstd writer error = %v", err)
+		}
+		w = zw
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w = fw
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compress error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compress close error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetch_HeadersAndRawBody(t *testing.T) {
+	body := compress(t, "br", []byte(page))
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	f := NewFetcher(Options{UserAgent: "test-bot/1.0", From: "crawler@example.com"})
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "something-else")
+
+	resp, err := f.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Fetch error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ua := got.Get("User-Agent"); ua != "test-bot/1.0" {
+		t.Errorf("User-Agent = %q; want test-bot/1.0", ua)
+	}
+	if from := got.Get("From"); from != "crawler@example.com" {
+		t.Errorf("From = %q; want crawler@example.com", from)
+	}
+	if ae := got.Get("Accept-Encoding"); ae != acceptEncoding {
+		t.Errorf("Accept-Encoding = %q; want %q", ae, acceptEncoding)
+	}
+	if resp.RemoteAddr != server.Listener.Addr().String() {
+		t.Errorf("RemoteAddr = %q; want %q", resp.RemoteAddr, server.Listener.Addr())
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(raw, body) {
+		t.Fatal("Fetch body was modified; want the raw encoded bytes")
+	}
+	decoded, err := Decode(resp.Header.Get("Content-Encoding"), raw, 1<<20)
+	if err != nil || string(decoded) != page {
+		t.Errorf("Decode = (%q, %v); want %q", decoded, err, page)
+	}
+}
+
+func TestFetch_DoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/next", http.StatusFound)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(Options{})
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := f.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Fetch error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("Fetch status = %d; want 302", resp.StatusCode)
+	}
+
+	clientResp, err := f.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Client.Get error = %v", err)
+	}
+	defer clientResp.Body.Close()
+	if clientResp.StatusCode != http.StatusOK {
+		t.Errorf("Client status = %d; want redirect followed to 200", clientResp.StatusCode)
+	}
+}
+
+func TestFetch_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	f := NewFetcher(Options{
+		TLSConfig:       server.Client().Transport.(*http.Transport).TLSClientConfig,
+		MaxConnsPerHost: 1,
+	})
+	for range 3 {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := f.Fetch(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Fetch error = %v", err)
+		}
+		proto, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 || string(proto) != "HTTP/2.0" {
+			t.Fatalf("protocol = %s (server saw %s); want HTTP/2", resp.Proto, proto)
+		}
+		if resp.RawResponseHeader != nil {
+			t.Errorf("RawResponseHeader = %q; want nil over HTTP/2", resp.RawResponseHeader)
+		}
+	}
+}
+
+// rawResponses is what the test server sends for each request, byte for byte:
+// an interim 103 that net/http skips, then headers with non-canonical case,
+// repeated and folded fields.
+var rawResponses = []string{
+	"HTTP/1.1 103 Early Hints\r\nlink: </style.css>; rel=preload\r\n\r\n" +
+		"HTTP/1.1 200 Fine Thanks\r\ncontent-type: text/html\r\nX-Repeat: one\r\nx-repeat: two\r\n" +
+		"Set-Cookie: a=1\r\nSet-Cookie: b=2\r\nX-Folded: first\r\n  second\r\nContent-Length: 5\r\n\r\nhello",
+	"HTTP/1.1 404 not here\r\nCONTENT-LENGTH: 3\r\nserver: raw\r\n\r\nnop",
+}
+
+func serveRaw(t *testing.T, listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for _, response := range rawResponses {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Errorf("read request error = %v", err)
+				return
+			}
+			if line == "\r\n" {
+				break
+			}
+		}
+		io.WriteString(conn, response)
+	}
+}
+
+func TestFetch_RawHeaders(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error = %v", err)
+	}
+	defer listener.Close()
+	go serveRaw(t, listener)
+
+	f := NewFetcher(Options{UserAgent: "test-bot/1.0"})
+	for i, raw := range rawResponses {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+"/page", nil)
+		resp, err := f.Fetch(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Fetch error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !strings.HasSuffix(raw, "\r\n\r\n"+string(body)) {
+			t.Fatalf("body = %q; want the end of %q", body, raw)
+		}
+		start := strings.LastIndex(raw, "HTTP/1.1 ")
+		want := raw[start : len(raw)-len(body)]
+		if got := string(resp.RawResponseHeader); got != want {
+			t.Errorf("response %d RawResponseHeader = %q; want %q", i, got, want)
+		}
+
+		request := string(resp.RawRequestHeader)
+		if !strings.HasPrefix(request, "GET /page HTTP/1.1\r\nHost: "+listener.Addr().String()+"\r\n") ||
+			!strings.Contains(request, "\r\nUser-Agent: test-bot/1.0\r\n") || !strings.HasSuffix(request, "\r\n\r\n") {
+			t.Errorf("request %d RawRequestHeader = %q; want the request as sent", i, request)
+		}
+	}
+}
+
+func TestFetch_RawHeadersOverTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(Options{
+		TLSConfig: server.Client().Transport.(*http.Transport).TLSClientConfig,
+	})
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := f.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Fetch error = %v", err)
+	}
+	resp.Body.Close()
+
+	if got := string(resp.RawResponseHeader); !strings.HasPrefix(got, "HTTP/1.1 200 OK\r\n") || !strings.HasSuffix(got, "\r\n\r\n") {
+		t.Errorf("RawResponseHeader = %q; want the plaintext header block", got)
+	}
+	if got := string(resp.RawRequestHeader); !strings.HasPrefix(got, "GET / HTTP/1.1\r\n") {
+		t.Errorf("RawRequestHeader = %q; want the plaintext request", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, coding := range []string{"gzip", "br", "zstd", "deflate"} {
+		got, err := Decode(coding, compress(t, coding, []byte(page)), 1<<20)
+		if err != nil || string(got) != page {
+			t.Errorf("Decode(%s) = (%q, %v); want %q", coding, got, err, page)
+		}
+	}
+
+	rawDeflate := compress(t, "raw-deflate", []byte(page))
+	if got, err := Decode("deflate", rawDeflate, 1<<20); err != nil || string(got) != page {
+		t.Errorf("Decode(raw deflate) = (%q, %v); want %q", got, err, page)
+	}
+
+	stacked := compress(t, "br", compress(t, "gzip", []byte(page)))
+	if got, err := Decode("gzip, br", stacked, 1<<20); err != nil || string(got) != page {
+		t.Errorf("Decode(gzip, br) = (%q, %v); want %q", got, err, page)
+	}
+
+	if got, err := Decode("", []byte(page), 4); err != nil || string(got) != page[:4] {
+		t.Errorf("Decode(identity, limit 4) = (%q, %v); want %q", got, err, page[:4])
+	}
+
+	if _, err := Decode("compress", []byte(page), 1<<20); err == nil {
+		t.Error("Decode(compress) error = nil; want unsupported encoding")
+	}
+}
+
+func TestDecode_LimitAndTruncation(t *testing.T) {
+	large := strings.Repeat("a", 1<<20)
+	encoded := compress(t, "gzip", []byte(large))
+
+	got, err := Decode("gzip", encoded, 1000)
+	if err != nil || len(got) != 1000 {
+		t.Errorf("Decode with limit = (%d bytes, %v); want 1000 bytes", len(got), err)
+	}
+
+	encoded = compress(t, "gzip", []byte(strings.Repeat(page, 1000)))
+	got, err = Decode("gzip", encoded[:len(encoded)/2], 1<<20)
+	if err != nil || len(got) == 0 || !strings.HasPrefix(strings.Repeat(page, 1000), string(got)) {
+		t.Errorf("Decode(truncated) = (%d bytes, %v); want a non-empty prefix", len(got), err)
+	}
+}
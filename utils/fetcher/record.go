@@ -0,0 +1,152 @@
+package fetcher
+
+import (
+	"bytes"
+	"net"
+	"sync"
+)
+
+// maxRecordedHeader bounds the bytes kept for one header block; a longer one
+// is not recorded and callers fall back to rebuilding it.
+const maxRecordedHeader = 1 << 20
+
+// recordingConn keeps the request and response header blocks of an HTTP/1.x
+// exchange exactly as they crossed the connection: the request line, status
+// line, field order, case, repeated and folded fields all survive, which
+// rebuilding them from an http.Header does not. Recording starts when Fetch
+// gets the connection and stops at the end of each header block, so bodies
+// are never buffered.
+type recordingConn struct {
+	net.Conn
+
+	mu        sync.Mutex
+	recording bool
+	request   headerTape
+	response  headerTape
+}
+
+func newRecordingConn(conn net.Conn) *recordingConn {
+	return &recordingConn{
+		Conn: conn,
+		mu:   sync.Mutex{},
+	}
+}
+
+// start begins recording a new exchange. HTTP/1.x carries one exchange at a
+// time, so nothing of the previous one can arrive afterwards.
+func (self *recordingConn) start() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.recording = true
+	self.request = headerTape{}
+	self.response = headerTape{final: true}
+}
+
+// headers returns the recorded header blocks, or nil for either that was not
+// completely seen.
+func (self *recordingConn) headers() ([]byte, []byte) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.request.block(), self.response.block()
+}
+
+func (self *recordingConn) Write(p []byte) (int, error) {
+	n, err := self.Conn.Write(p)
+
+	self.mu.Lock()
+	if self.recording {
+		self.request.write(p[:n])
+	}
+	self.mu.Unlock()
+
+	return n, err
+}
+
+func (self *recordingConn) Read(p []byte) (int, error) {
+	n, err := self.Conn.Read(p)
+
+	self.mu.Lock()
+	if self.recording {
+		self.response.write(p[:n])
+	}
+	self.mu.Unlock()
+
+	return n, err
+}
+
+// headerTape collects bytes up to the blank line ending a header block. With
+// final set, interim 1xx responses such as 100 Continue and 103 Early Hints
+// are skipped, as net/http skips them, and the tape waits for the response
+// that is returned.
+type headerTape struct {
+	final    bool
+	buf      []byte
+	done     bool
+	overflow bool
+}
+
+func (self *headerTape) write(p []byte) {
+	if self.done || self.overflow || len(p) == 0 {
+		return
+	}
+	self.buf = append(self.buf, p...)
+
+	for {
+		end := headerEnd(self.buf)
+		if end < 0 {
+			break
+		}
+		if self.final && interim(self.buf[:end]) {
+			self.buf = append(self.buf[:0], self.buf[end:]...)
+			continue
+		}
+		self.buf = self.buf[:end:end]
+		self.done = true
+		return
+	}
+
+	if len(self.buf) > maxRecordedHeader {
+		self.buf = nil
+		self.overflow = true
+	}
+}
+
+func (self *headerTape) block() []byte {
+	if !self.done {
+		return nil
+	}
+
+	return self.buf
+}
+
+// headerEnd returns the length of the header block at the start of b,
+// including the empty line that ends it, or -1 if it is not complete. Bare LF
+// line endings are accepted as net/http accepts them.
+func headerEnd(b []byte) int {
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\n' {
+			continue
+		}
+		switch {
+		case i+1 < len(b) && b[i+1] == '\n':
+			return i + 2
+		case i+2 < len(b) && b[i+1] == '\r' && b[i+2] == '\n':
+			return i + 3
+		}
+	}
+
+	return -1
+}
+
+// interim reports a 1xx status line other than 101 Switching Protocols, which
+// ends the exchange.
+func interim(block []byte) bool {
+	_, status, ok := bytes.Cut(block, []byte(" "))
+	if !ok || len(status) < 3 {
+		return false
+	}
+
+	return status[0] == '1' && !bytes.HasPrefix(status, []byte("101"))
+}
@@ -1,3 +1,20 @@
 module github.com/guilherme13c/go-search/utils
 
 go 1.24.2
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.15.12
+	github.com/nlnwa/gowarc v1.6.0
+	golang.org/x/net v0.23.0
+	golang.org/x/text v0.14.0
+)
+
+require (
+	github.com/bits-and-blooms/bitset v1.5.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/nlnwa/whatwg-url v0.4.0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/prometheus/prometheus v0.40.3 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
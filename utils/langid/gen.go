@@ -0,0 +1,267 @@
+//go:build ignore
+
+// Gen builds the bundled language profiles from the corpus vendored under
+// testdata/corpus, one message per line and one file per language, so the
+// profiles depend on nothing but the tree.
+//
+//	go run gen.go -corpus testdata/corpus -out profiles
+//
+// The corpus itself was sampled from the translated messages of gettext
+// catalogs, the English one from the untranslated message ids. Given a
+// catalog directory, gen samples it again and rewrites the corpus first:
+//
+//	go run gen.go -locales /usr/share/locale -corpus testdata/corpus -out profiles
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/guilherme13c/go-search/utils/langid"
+)
+
+// sources lists the locale directories read for each language.
+var sources = map[string][]string{
+	"af": {"af"}, "ar": {"ar"}, "be": {"be"}, "bg": {"bg"}, "bn": {"bn", "bn_IN", "bn_BD"},
+	"ca": {"ca"}, "cs": {"cs"}, "cy": {"cy"}, "da": {"da"}, "de": {"de"}, "el": {"el"},
+	"es": {"es"}, "et": {"et"}, "eu": {"eu"}, "fa": {"fa"}, "fi": {"fi"}, "fr": {"fr"},
+	"ga": {"ga"}, "gl": {"gl"}, "he": {"he"}, "hi": {"hi"}, "hr": {"hr"}, "hu": {"hu"},
+	"hy": {"hy"}, "id": {"id"}, "it": {"it"}, "ja": {"ja"}, "ka": {"ka"},
+	"ko": {"ko"}, "lt": {"lt"}, "lv": {"lv"}, "mk": {"mk"}, "ms": {"ms"}, "nb": {"nb", "no"},
+	"nl": {"nl"}, "pl": {"pl"}, "pt": {"pt", "pt_BR"}, "ro": {"ro"}, "ru": {"ru"},
+	"sk": {"sk"}, "sl": {"sl"}, "sq": {"sq"}, "sr": {"sr"}, "sv": {"sv"}, "ta": {"ta"},
+	"th": {"th"}, "tr": {"tr"}, "uk": {"uk"}, "vi": {"vi"}, "zh": {"zh_CN", "zh_TW"},
+}
+
+// englishFrom is the catalog set whose message ids make up the English corpus.
+const englishFrom = "de"
+
+var (
+	formatPattern = regexp.MustCompile(`%(\d+\$)?[-+ #0]*(\d+|\*)?(\.\d+)?(hh|h|ll|l|L|q|j|z|t)?[a-zA-Z%]|\{[^}]*\}|<[^>]*>|\$\{[^}]*\}|&[a-z]+;|https?://\S+|\S*[_=/\\@]\S*|(^|\s)--?\w[\w-]*`)
+	limits        = [langid.MaxOrder + 1]int{0, 200, 400, 800}
+)
+
+// sampleSize bounds the bytes of messages kept in the corpus per language.
+const sampleSize = 64 << 10
+
+func main() {
+	locales := flag.String("locales", "", "directory of gettext catalogs to sample the corpus from")
+	corpus := flag.String("corpus", "testdata/corpus", "corpus directory")
+	out := flag.String("out", "profiles", "output directory")
+	flag.Parse()
+
+	langs := append(slices.Sorted(maps.Keys(sources)), "en")
+	if *locales != "" {
+		if err := os.MkdirAll(*corpus, 0755); err != nil {
+			fail(err)
+		}
+		for _, lang := range langs {
+			if err := sampleCatalogs(*locales, lang, filepath.Join(*corpus, lang+".txt")); err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fail(err)
+	}
+	for _, lang := range langs {
+		texts, err := readCorpus(filepath.Join(*corpus, lang+".txt"))
+		if err != nil {
+			fail(err)
+		}
+
+		counts := map[string]int{}
+		var totals [langid.MaxOrder + 1]int
+		for _, text := range texts {
+			for _, gram := range langid.Ngrams(text, 0) {
+				counts[gram]++
+				totals[len([]rune(gram))]++
+			}
+		}
+		if err := writeProfile(filepath.Join(*out, lang+".txt"), counts, totals); err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s: %d messages, %d n-grams\n", lang, len(texts), totals[1])
+	}
+}
+
+// sampleCatalogs writes up to sampleSize bytes of the cleaned messages of a
+// language's catalogs to path. Messages are taken in the order of their hash,
+// which spreads the sample over every catalog and keeps it stable when
+// catalogs are added.
+func sampleCatalogs(locales, lang, path string) error {
+	texts := map[string]struct{}{}
+	dirs := sources[lang]
+	if lang == "en" {
+		dirs = []string{englishFrom}
+	}
+	for _, dir := range dirs {
+		catalogs, _ := filepath.Glob(filepath.Join(locales, dir, "LC_MESSAGES", "*.mo"))
+		for _, path := range catalogs {
+			// Catalogs of language, country and script names are
+			// mostly proper nouns shared between languages.
+			if strings.HasPrefix(filepath.Base(path), "iso_") {
+				continue
+			}
+			if err := readCatalog(path, lang == "en", texts); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("no messages for %s in %s", lang, locales)
+	}
+
+	hash := func(s string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		return h.Sum64()
+	}
+	sample := slices.SortedFunc(maps.Keys(texts), func(a, b string) int {
+		if c := cmp.Compare(hash(a), hash(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var b strings.Builder
+	for _, text := range sample {
+		if b.Len()+len(text)+1 > sampleSize {
+			break
+		}
+		b.WriteString(text)
+		b.WriteByte('\n')
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// readCorpus returns the messages of a corpus file, one per line.
+func readCorpus(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	texts := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if text := scanner.Text(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return texts, scanner.Err()
+}
+
+// readCatalog adds the cleaned messages of a .mo file to texts: the
+// translations, or the message ids when ids is set. Translations left equal
+// to their id are English and skipped.
+func readCatalog(path string, ids bool, texts map[string]struct{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 20 {
+		return errors.New("short catalog")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	switch order.Uint32(data) {
+	case 0x950412de:
+	case 0xde120495:
+		order = binary.BigEndian
+	default:
+		return errors.New("not a gettext catalog")
+	}
+
+	n := int(order.Uint32(data[8:]))
+	originals, translations := int(order.Uint32(data[12:])), int(order.Uint32(data[16:]))
+	str := func(table, i int) (string, bool) {
+		at := table + 8*i
+		if at+8 > len(data) {
+			return "", false
+		}
+		length, offset := int(order.Uint32(data[at:])), int(order.Uint32(data[at+4:]))
+		if offset+length > len(data) {
+			return "", false
+		}
+		return string(data[offset : offset+length]), true
+	}
+
+	for i := range n {
+		original, ok1 := str(originals, i)
+		translation, ok2 := str(translations, i)
+		if !ok1 || !ok2 || original == "" {
+			continue
+		}
+		// Drop the message context, then split plural forms.
+		if _, id, ok := strings.Cut(original, "\x04"); ok {
+			original = id
+		}
+		text := translation
+		if ids {
+			text = original
+		} else if translation == original {
+			continue
+		}
+		for _, s := range strings.Split(text, "\x00") {
+			s = strings.Join(strings.Fields(formatPattern.ReplaceAllString(s, " ")), " ")
+			if len([]rune(s)) >= 3 {
+				texts[s] = struct{}{}
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeProfile keeps the most frequent n-grams of each order. The first line
+// holds the total count of every order, from which the runtime derives
+// probabilities.
+func writeProfile(path string, counts map[string]int, totals [langid.MaxOrder + 1]int) error {
+	var b strings.Builder
+	for n := 1; n <= langid.MaxOrder; n++ {
+		if n > 1 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprint(&b, totals[n])
+	}
+	b.WriteByte('\n')
+
+	for n := 1; n <= langid.MaxOrder; n++ {
+		grams := []string{}
+		for gram := range counts {
+			if len([]rune(gram)) == n {
+				grams = append(grams, gram)
+			}
+		}
+		slices.SortFunc(grams, func(a, b string) int {
+			if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		})
+		for _, gram := range grams[:min(len(grams), limits[n])] {
+			fmt.Fprintf(&b, "%s\t%d\n", gram, counts[gram])
+		}
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
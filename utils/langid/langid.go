@@ -0,0 +1,226 @@
+package langid
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:generate go run gen.go -corpus testdata/corpus -out profiles
+
+//go:embed profiles/*.txt
+var profiles embed.FS
+
+// Options tune how text and hints are weighed. At most MaxNgrams n-grams of
+// a text are read. Evidence caps the number of n-grams whose likelihood
+// counts in full, so that confidence reflects how distinct the languages are
+// rather than growing with the length of the text. A hinted language starts
+// with HintWeight times the prior odds of the others.
+type Options struct {
+	MaxNgrams  int
+	Evidence   int
+	HintWeight float64
+}
+
+const (
+	DefaultMaxNgrams  = 4000
+	DefaultEvidence   = 150
+	DefaultHintWeight = 20
+)
+
+// Result is the most likely language as an ISO 639-1 code with its posterior
+// probability, or an empty Language when neither the text nor the hints say
+// anything.
+type Result struct {
+	Language   string
+	Confidence float64
+}
+
+type Identifier interface {
+	Identify(text string, hints ...string) Result
+	Languages() []string
+}
+
+type weight struct {
+	lang int
+	gain float64
+}
+
+// model holds, for every profiled n-gram, how much more likely each language
+// makes it than the floor probability given to n-grams a profile lacks.
+type model struct {
+	langs []string
+	index map[string]int
+	grams map[string][]weight
+}
+
+var loadModel = sync.OnceValues(func() (*model, error) {
+	entries, err := profiles.ReadDir("profiles")
+	if err != nil {
+		return nil, fmt.Errorf("read profiles error: %w", err)
+	}
+
+	m := &model{index: make(map[string]int), grams: make(map[string][]weight)}
+	probs := []map[string]float64{}
+	floors := [MaxOrder + 1]float64{}
+	for i := range floors {
+		floors[i] = 1
+	}
+	for _, entry := range entries {
+		lang := strings.TrimSuffix(entry.Name(), ".txt")
+		p, err := readProfile(path.Join("profiles", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("profile %s error: %w", lang, err)
+		}
+		for gram, prob := range p {
+			n := utf8.RuneCountInString(gram)
+			floors[n] = min(floors[n], prob/2)
+		}
+		m.index[lang] = len(m.langs)
+		m.langs = append(m.langs, lang)
+		probs = append(probs, p)
+	}
+
+	for lang, p := range probs {
+		for gram, prob := range p {
+			gain := math.Log(prob / floors[utf8.RuneCountInString(gram)])
+			m.grams[gram] = append(m.grams[gram], weight{lang: lang, gain: gain})
+		}
+	}
+
+	return m, nil
+})
+
+func readProfile(name string) (map[string]float64, error) {
+	file, err := profiles.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("missing totals")
+	}
+	totals := [MaxOrder + 1]float64{}
+	for i, field := range strings.Fields(scanner.Text()) {
+		if i >= MaxOrder {
+			break
+		}
+		total, err := strconv.Atoi(field)
+		if err != nil || total <= 0 {
+			return nil, fmt.Errorf("invalid total %q", field)
+		}
+		totals[i+1] = float64(total)
+	}
+
+	res := make(map[string]float64)
+	for scanner.Scan() {
+		gram, count, ok := strings.Cut(scanner.Text(), "\t")
+		n := utf8.RuneCountInString(gram)
+		if !ok || n < 1 || n > MaxOrder || totals[n] == 0 {
+			return nil, fmt.Errorf("invalid line %q", scanner.Text())
+		}
+		c, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("invalid count %q", count)
+		}
+		res[gram] = float64(c) / totals[n]
+	}
+
+	return res, scanner.Err()
+}
+
+type identifier struct {
+	opts  Options
+	model *model
+}
+
+func NewIdentifier(opts Options) (Identifier, error) {
+	if opts.MaxNgrams <= 0 {
+		opts.MaxNgrams = DefaultMaxNgrams
+	}
+	if opts.Evidence <= 0 {
+		opts.Evidence = DefaultEvidence
+	}
+	if opts.HintWeight <= 0 {
+		opts.HintWeight = DefaultHintWeight
+	}
+
+	m, err := loadModel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &identifier{
+		opts:  opts,
+		model: m,
+	}, nil
+}
+
+func (self *identifier) Languages() []string {
+	return slices.Clone(self.model.langs)
+}
+
+// Identify scores text with a naive Bayes model over its character n-grams
+// and combines the result with hints, such as the <html lang> attribute and
+// the Content-Language header, as prior odds. Hints for languages without a
+// profile are ignored.
+func (self *identifier) Identify(text string, hints ...string) Result {
+	scores := make([]float64, len(self.model.langs))
+
+	matched := 0
+	for _, gram := range Ngrams(text, self.opts.MaxNgrams) {
+		weights, ok := self.model.grams[gram]
+		if !ok {
+			continue
+		}
+		matched++
+		for _, w := range weights {
+			scores[w.lang] += w.gain
+		}
+	}
+	// Every character starts an n-gram of each order, so the orders repeat
+	// each other's evidence; counting it once keeps the posterior honest.
+	scale := 1.0 / MaxOrder
+	if matched > self.opts.Evidence {
+		scale *= float64(self.opts.Evidence) / float64(matched)
+	}
+	for i := range scores {
+		scores[i] *= scale
+	}
+
+	hinted := make(map[int]bool)
+	for _, hint := range hints {
+		if lang, ok := self.model.index[Normalize(hint)]; ok && !hinted[lang] {
+			hinted[lang] = true
+			scores[lang] += math.Log(self.opts.HintWeight)
+		}
+	}
+	if matched == 0 && len(hinted) == 0 {
+		return Result{}
+	}
+
+	best := 0
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+
+	return Result{
+		Language:   self.model.langs[best],
+		Confidence: 1 / sum,
+	}
+}
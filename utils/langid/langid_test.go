@@ -0,0 +1,112 @@
+package langid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNgrams(t *testing.T) {
+	got := Ngrams("Ab, c!", 0)
+	want := []string{" a", " ab", "a", "ab", "ab ", "b", "b ", " c", " c ", "c", "c "}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Ngrams = %q; want %q", got, want)
+	}
+	if got := Ngrams("hello world", 4); len(got) != 4 {
+		t.Errorf("Ngrams(limit 4) = %q; want 4 n-grams", got)
+	}
+	// Combining marks belong to the word: Devanagari vowel signs are marks.
+	if got := Ngrams("कि", 0); !strings.Contains(strings.Join(got, "|"), "कि") {
+		t.Errorf("Ngrams(कि) = %q; want the vowel sign kept with its consonant", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{
+		"en-GB":    "en",
+		"pt_BR":    "pt",
+		" ZH-Hant": "zh",
+		"iw":       "he",
+		"no":       "nb",
+		"deu":      "de",
+		"":         "",
+	} {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q; want %q", tag, got, want)
+		}
+	}
+}
+
+var samples = map[string]string{
+	"en": "The committee will publish its report on the state of public libraries next month, after hearing from readers and staff.",
+	"de": "Der Ausschuss wird seinen Bericht über die Lage der öffentlichen Bibliotheken nächsten Monat veröffentlichen, nachdem er Leser und Mitarbeiter angehört hat.",
+	"fr": "Le comité publiera le mois prochain son rapport sur l'état des bibliothèques publiques, après avoir entendu les lecteurs et le personnel.",
+	"es": "El comité publicará el próximo mes su informe sobre el estado de las bibliotecas públicas, después de escuchar a los lectores y al personal.",
+	"pt": "O comitê publicará no próximo mês o seu relatório sobre a situação das bibliotecas públicas, depois de ouvir os leitores e os funcionários.",
+	"it": "Il comitato pubblicherà il mese prossimo il suo rapporto sullo stato delle biblioteche pubbliche, dopo aver ascoltato lettori e personale.",
+	"nl": "De commissie publiceert volgende maand haar rapport over de toestand van de openbare bibliotheken, nadat zij lezers en personeel heeft gehoord.",
+	"sv": "Kommittén kommer att publicera sin rapport om de offentliga bibliotekens tillstånd nästa månad, efter att ha hört läsare och personal.",
+	"pl": "Komisja opublikuje w przyszłym miesiącu swój raport o stanie bibliotek publicznych, po wysłuchaniu czytelników i pracowników.",
+	"cs": "Výbor zveřejní příští měsíc svou zprávu o stavu veřejných knihoven poté, co vyslechne čtenáře a zaměstnance.",
+	"hu": "A bizottság a jövő hónapban teszi közzé jelentését a közkönyvtárak helyzetéről, miután meghallgatta az olvasókat és a munkatársakat.",
+	"fi": "Valiokunta julkaisee ensi kuussa raporttinsa yleisten kirjastojen tilasta kuultuaan lukijoita ja henkilökuntaa.",
+	"tr": "Komite, okuyucuları ve personeli dinledikten sonra halk kütüphanelerinin durumu hakkındaki raporunu gelecek ay yayımlayacak.",
+	"ru": "Комитет опубликует в следующем месяце свой доклад о состоянии публичных библиотек, после того как выслушает читателей и сотрудников.",
+	"uk": "Комітет опублікує наступного місяця свою доповідь про стан публічних бібліотек, після того як вислухає читачів і працівників.",
+	"el": "Η επιτροπή θα δημοσιεύσει τον επόμενο μήνα την έκθεσή της για την κατάσταση των δημόσιων βιβλιοθηκών, αφού ακούσει αναγνώστες και προσωπικό.",
+	"ar": "ستنشر اللجنة تقريرها عن حالة المكتبات العامة في الشهر المقبل بعد الاستماع إلى القراء والموظفين.",
+	"he": "הוועדה תפרסם בחודש הבא את הדוח שלה על מצב הספריות הציבוריות, לאחר שתשמע את הקוראים ואת הצוות.",
+	"ja": "委員会は、読者と職員の意見を聞いた上で、来月公共図書館の現状に関する報告書を公表する予定です。",
+	"zh": "委员会将在听取读者和工作人员的意见后，于下个月发布关于公共图书馆状况的报告。",
+	"ko": "위원회는 독자와 직원의 의견을 들은 후 다음 달에 공공 도서관의 상태에 관한 보고서를 발표할 예정이다.",
+	"vi": "Ủy ban sẽ công bố báo cáo về tình trạng của các thư viện công cộng vào tháng tới, sau khi lắng nghe ý kiến của độc giả và nhân viên.",
+	"th": "คณะกรรมการจะเผยแพร่รายงานเกี่ยวกับสถานะของห้องสมุดสาธารณะในเดือนหน้า หลังจากรับฟังความคิดเห็นของผู้อ่านและเจ้าหน้าที่",
+	"hi": "समिति पाठकों और कर्मचारियों की बात सुनने के बाद अगले महीने सार्वजनिक पुस्तकालयों की स्थिति पर अपनी रिपोर्ट प्रकाशित करेगी।",
+}
+
+func TestIdentify(t *testing.T) {
+	id, err := NewIdentifier(Options{})
+	if err != nil {
+		t.Fatalf("NewIdentifier error = %v", err)
+	}
+	if n := len(id.Languages()); n < 30 {
+		t.Fatalf("Languages() has %d profiles; want at least 30", n)
+	}
+
+	for lang, text := range samples {
+		got := id.Identify(text)
+		if got.Language != lang || got.Confidence < 0.9 {
+			t.Errorf("Identify(%s sample) = %+v; want %s with confidence >= 0.9", lang, got, lang)
+		}
+	}
+
+	if got := id.Identify("12345 !!! ---"); got != (Result{}) {
+		t.Errorf("Identify(no letters) = %+v; want no result", got)
+	}
+}
+
+func TestIdentify_Hints(t *testing.T) {
+	id, err := NewIdentifier(Options{})
+	if err != nil {
+		t.Fatalf("NewIdentifier error = %v", err)
+	}
+
+	// A hint settles text too short to tell related languages apart...
+	plain := id.Identify("o comitê")
+	hinted := id.Identify("o comitê", "pt-BR")
+	if hinted.Language != "pt" || hinted.Confidence <= plain.Confidence && plain.Language == "pt" {
+		t.Errorf("Identify(short, pt-BR) = %+v (without hint %+v); want pt with more confidence", hinted, plain)
+	}
+
+	// ...but does not override clear evidence, as a wrong lang attribute is common.
+	if got := id.Identify(samples["en"], "fr"); got.Language != "en" {
+		t.Errorf("Identify(English, fr) = %+v; want en", got)
+	}
+
+	got := id.Identify("", "de-AT", "de")
+	if got.Language != "de" || got.Confidence <= 0 || got.Confidence >= 0.9 {
+		t.Errorf("Identify(empty, de) = %+v; want de with low confidence", got)
+	}
+	if got := id.Identify("", "xx"); got != (Result{}) {
+		t.Errorf("Identify(empty, unknown hint) = %+v; want no result", got)
+	}
+}
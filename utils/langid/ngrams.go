@@ -0,0 +1,91 @@
+package langid
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxOrder is the longest character n-gram in the profiles.
+const MaxOrder = 3
+
+// Ngrams returns the character n-grams of orders 1 to MaxOrder in text, at
+// most limit of them when limit is positive. Words are lowercased runs of
+// letters and combining marks, padded with a space on each side so the
+// n-grams also capture how words begin and end; digits and punctuation
+// separate words.
+func Ngrams(text string, limit int) []string {
+	res := []string{}
+	word := []rune{' '}
+	flush := func() bool {
+		if len(word) == 1 {
+			return true
+		}
+		word = append(word, ' ')
+		for i := range word {
+			for n := 1; n <= MaxOrder && i+n <= len(word); n++ {
+				gram := word[i : i+n]
+				if n == 1 && gram[0] == ' ' {
+					continue
+				}
+				res = append(res, string(gram))
+				if limit > 0 && len(res) >= limit {
+					return false
+				}
+			}
+		}
+		word = word[:1]
+		return true
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			word = append(word, unicode.ToLower(r))
+			continue
+		}
+		if !flush() {
+			return res
+		}
+	}
+	flush()
+
+	return res
+}
+
+// Normalize reduces a language tag such as "en-GB", "pt_BR" or "zh-Hant" to
+// the ISO 639-1 code the profiles use, mapping deprecated and macrolanguage
+// codes to their current equivalents.
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	primary, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if alias, ok := aliases[primary]; ok {
+		return alias
+	}
+
+	return primary
+}
+
+var aliases = map[string]string{
+	"iw":  "he",
+	"in":  "id",
+	"ji":  "yi",
+	"no":  "nb",
+	"nn":  "nb",
+	"nob": "nb",
+	"sh":  "hr",
+	"zsm": "ms",
+	"cmn": "zh",
+	"eng": "en",
+	"deu": "de",
+	"ger": "de",
+	"fra": "fr",
+	"fre": "fr",
+	"spa": "es",
+	"por": "pt",
+	"ita": "it",
+	"rus": "ru",
+	"jpn": "ja",
+	"zho": "zh",
+	"chi": "zh",
+	"kor": "ko",
+	"ara": "ar",
+}
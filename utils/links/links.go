@@ -0,0 +1,394 @@
+package links
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+type Source string
+
+const (
+	SourceAnchor      Source = "a"
+	SourceArea        Source = "area"
+	SourceLink        Source = "link"
+	SourceIframe      Source = "iframe"
+	SourceFrame       Source = "frame"
+	SourceMetaRefresh Source = "meta-refresh"
+	SourceImage       Source = "img"
+	SourceSrcset      Source = "srcset"
+	SourceHeader      Source = "http-link"
+)
+
+type Link struct {
+	URL    string
+	Text   string
+	Rel    []string
+	Source Source
+}
+
+func (self Link) HasRel(rel string) bool {
+	for _, r := range self.Rel {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Options struct {
+	Srcset bool
+	Images bool
+}
+
+type Extractor interface {
+	Extract(base *url.URL, r io.Reader) ([]Link, error)
+	ExtractNode(base *url.URL, root *html.Node) []Link
+}
+
+type extractor struct {
+	opts Options
+}
+
+func NewExtractor(opts Options) Extractor {
+	return &extractor{
+		opts: opts,
+	}
+}
+
+var followedLinkRels = map[string]struct{}{
+	"alternate": {},
+	"canonical": {},
+	"next":      {},
+}
+
+func (self *extractor) Extract(base *url.URL, r io.Reader) ([]Link, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	return self.ExtractNode(base, root), nil
+}
+
+func (self *extractor) ExtractNode(base *url.URL, root *html.Node) []Link {
+	if root == nil {
+		return nil
+	}
+	if b := findBase(root); b != "" {
+		if u, err := resolve(base, b); err == nil {
+			base = u
+		}
+	}
+
+	res := []Link{}
+	add := func(ref string, text string, rel []string, source Source) {
+		u, err := resolve(base, ref)
+		if err != nil {
+			return
+		}
+		res = append(res, Link{
+			URL:    u.String(),
+			Text:   text,
+			Rel:    rel,
+			Source: source,
+		})
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "a":
+				if href, ok := attr(n, "href"); ok {
+					add(href, textContent(n), relList(n), SourceAnchor)
+				}
+
+			case "area":
+				if href, ok := attr(n, "href"); ok {
+					alt, _ := attr(n, "alt")
+					add(href, collapseSpace(alt), relList(n), SourceArea)
+				}
+
+			case "link":
+				rel := relList(n)
+				if href, ok := attr(n, "href"); ok && hasAnyRel(rel, followedLinkRels) {
+					title, _ := attr(n, "title")
+					add(href, collapseSpace(title), rel, SourceLink)
+				}
+
+			case "iframe":
+				if src, ok := attr(n, "src"); ok {
+					add(src, "", nil, SourceIframe)
+				}
+
+			case "frame":
+				if src, ok := attr(n, "src"); ok {
+					add(src, "", nil, SourceFrame)
+				}
+
+			case "meta":
+				equiv, _ := attr(n, "http-equiv")
+				content, _ := attr(n, "content")
+				if strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+					if ref, ok := ParseRefresh(content); ok {
+						add(ref, "", nil, SourceMetaRefresh)
+					}
+				}
+
+			case "img":
+				alt, _ := attr(n, "alt")
+				if src, ok := attr(n, "src"); ok && self.opts.Images {
+					add(src, collapseSpace(alt), nil, SourceImage)
+				}
+				if srcset, ok := attr(n, "srcset"); ok && self.opts.Srcset {
+					for _, ref := range ParseSrcset(srcset) {
+						add(ref, collapseSpace(alt), nil, SourceSrcset)
+					}
+				}
+
+			case "source":
+				if srcset, ok := attr(n, "srcset"); ok && self.opts.Srcset {
+					for _, ref := range ParseSrcset(srcset) {
+						add(ref, "", nil, SourceSrcset)
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	return res
+}
+
+func ParseLinkHeader(base *url.URL, values []string) []Link {
+	res := []Link{}
+
+	for _, value := range values {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			if start == -1 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end == -1 {
+				break
+			}
+			ref := value[start+1 : start+end]
+			value = value[start+end+1:]
+
+			params := value
+			if next := strings.IndexByte(value, '<'); next != -1 {
+				params = value[:next]
+				value = value[next:]
+			} else {
+				value = ""
+			}
+
+			var rel []string
+			for _, param := range strings.Split(params, ";") {
+				key, val, ok := strings.Cut(param, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				rel = strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(val), "\",")))
+			}
+
+			u, err := resolve(base, ref)
+			if err != nil {
+				continue
+			}
+			res = append(res, Link{
+				URL:    u.String(),
+				Rel:    rel,
+				Source: SourceHeader,
+			})
+		}
+	}
+
+	return res
+}
+
+func Canonical(links ...[]Link) (string, bool) {
+	for _, ls := range links {
+		for _, l := range ls {
+			if l.HasRel("canonical") {
+				return l.URL, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func ParseRefresh(content string) (string, bool) {
+	content = strings.TrimSpace(content)
+
+	idx := strings.IndexAny(content, ";,")
+	if idx == -1 {
+		return "", false
+	}
+	rest := strings.TrimSpace(content[idx+1:])
+
+	if len(rest) >= 3 && strings.EqualFold(rest[:3], "url") {
+		rest = strings.TrimSpace(rest[3:])
+		if !strings.HasPrefix(rest, "=") {
+			return "", false
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+
+	if len(rest) > 0 && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		rest = rest[1:]
+		if end := strings.IndexByte(rest, quote); end != -1 {
+			rest = rest[:end]
+		}
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return "", false
+	}
+
+	return rest, true
+}
+
+func ParseSrcset(srcset string) []string {
+	res := []string{}
+
+	s := srcset
+	for {
+		s = strings.TrimLeftFunc(s, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ','
+		})
+		if s == "" {
+			break
+		}
+
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end == -1 {
+			end = len(s)
+		}
+		candidate := s[:end]
+		s = s[end:]
+
+		if strings.HasSuffix(candidate, ",") {
+			candidate = strings.TrimRight(candidate, ",")
+		} else if idx := strings.IndexByte(s, ','); idx != -1 {
+			s = s[idx+1:]
+		} else {
+			s = ""
+		}
+
+		if candidate != "" {
+			res = append(res, candidate)
+		}
+	}
+
+	return res
+}
+
+func resolve(base *url.URL, ref string) (*url.URL, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("empty reference")
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u, nil
+}
+
+func findBase(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "base" {
+		if href, ok := attr(n, "href"); ok {
+			return href
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href := findBase(c); href != "" {
+			return href
+		}
+	}
+
+	return ""
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
+
+func relList(n *html.Node) []string {
+	rel, ok := attr(n, "rel")
+	if !ok {
+		return nil
+	}
+
+	return strings.Fields(strings.ToLower(rel))
+}
+
+func hasAnyRel(rel []string, want map[string]struct{}) bool {
+	for _, r := range rel {
+		if _, ok := want[r]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		case html.ElementNode:
+			if n.Data == "img" {
+				if alt, ok := attr(n, "alt"); ok {
+					sb.WriteString(alt)
+					sb.WriteByte(' ')
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return collapseSpace(sb.String())
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,167 @@
+package links
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+	<link rel="canonical" href="/canonical">
+	<link rel="stylesheet" href="/style.css">
+	<link rel="alternate" hreflang="pt" href="https://example.com/pt/" title="Portugues">
+	<meta http-equiv="refresh" content="5; url='/refreshed'">
+</head>
+<body>
+	<a href="/about" rel="nofollow">About   <b>us</b></a>
+	<a href="relative#section">Relative</a>
+	<a href="mailto:someone@example.com">Mail</a>
+	<a href="javascript:void(0)">Script</a>
+	<a>No href</a>
+	<map><area href="/area" alt="Area link"></map>
+	<iframe src="https://other.example.org/embed"></iframe>
+	<frameset><frame src="/frame"></frameset>
+	<img src="/img.png" alt="Picture" srcset="/img-1x.png 1x, /img-2x.png 2x">
+</body>
+</html>`
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) error = %v", raw, err)
+	}
+	return u
+}
+
+func TestExtract_DefaultSources(t *testing.T) {
+	e := NewExtractor(Options{})
+	got, err := e.Extract(mustParse(t, "https://example.com/dir/page"), strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Extract error = %v; want nil", err)
+	}
+
+	want := []Link{
+		{URL: "https://example.com/canonical", Rel: []string{"canonical"}, Source: SourceLink},
+		{URL: "https://example.com/pt/", Text: "Portugues", Rel: []string{"alternate"}, Source: SourceLink},
+		{URL: "https://example.com/refreshed", Source: SourceMetaRefresh},
+		{URL: "https://example.com/about", Text: "About us", Rel: []string{"nofollow"}, Source: SourceAnchor},
+		{URL: "https://example.com/dir/relative", Text: "Relative", Source: SourceAnchor},
+		{URL: "https://example.com/area", Text: "Area link", Source: SourceArea},
+		{URL: "https://other.example.org/embed", Source: SourceIframe},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestExtract_FramesetDocument(t *testing.T) {
+	doc := `<html><frameset><frame src="/left"><frame src="right"></frameset></html>`
+	got, err := NewExtractor(Options{}).Extract(mustParse(t, "http://example.com/"), strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Extract error = %v; want nil", err)
+	}
+	if len(got) != 2 || got[0].URL != "http://example.com/left" || got[1].URL != "http://example.com/right" {
+		t.Errorf("Extract = %#v; want /left and /right frames", got)
+	}
+}
+
+func TestExtract_ImagesAndSrcset(t *testing.T) {
+	e := NewExtractor(Options{Images: true, Srcset: true})
+	got, err := e.Extract(mustParse(t, "https://example.com/"), strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Extract error = %v; want nil", err)
+	}
+
+	var images []string
+	for _, l := range got {
+		if l.Source == SourceImage || l.Source == SourceSrcset {
+			images = append(images, l.URL)
+		}
+	}
+	want := []string{
+		"https://example.com/img.png",
+		"https://example.com/img-1x.png",
+		"https://example.com/img-2x.png",
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("image links = %v; want %v", images, want)
+	}
+}
+
+func TestExtract_BaseHref(t *testing.T) {
+	doc := `<html><head><base href="https://cdn.example.net/root/"></head><body><a href="x">X</a></body></html>`
+	got, err := NewExtractor(Options{}).Extract(mustParse(t, "https://example.com/"), strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Extract error = %v; want nil", err)
+	}
+	if len(got) != 1 || got[0].URL != "https://cdn.example.net/root/x" {
+		t.Errorf("Extract = %#v; want link resolved against <base>", got)
+	}
+}
+
+func TestLink_HasRel(t *testing.T) {
+	l := Link{Rel: []string{"nofollow", "noopener"}}
+	if !l.HasRel("NoFollow") {
+		t.Error("HasRel(\"NoFollow\") = false; want true")
+	}
+	if l.HasRel("canonical") {
+		t.Error("HasRel(\"canonical\") = true; want false")
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	cases := map[string]string{
+		"0; url=/next":          "/next",
+		"5;URL='/quoted'":       "/quoted",
+		"3, http://example.com": "http://example.com",
+		"10":                    "",
+		"0; url=":               "",
+	}
+	for in, want := range cases {
+		got, ok := ParseRefresh(in)
+		if got != want || ok != (want != "") {
+			t.Errorf("ParseRefresh(%q) = (%q, %v); want (%q, %v)", in, got, ok, want, want != "")
+		}
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	got := ParseSrcset(" a.png 1x,b.png, c.png 480w ")
+	want := []string{"a.png", "b.png", "c.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSrcset = %v; want %v", got, want)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	got := ParseLinkHeader(mustParse(t, "https://example.com/a/b"), []string{
+		`</canonical>; rel="canonical", <https://example.com/page/2>; rel=next`,
+		`<https://example.com/x>; title="Title"; rel="alternate nofollow"`,
+	})
+	want := []Link{
+		{URL: "https://example.com/canonical", Rel: []string{"canonical"}, Source: SourceHeader},
+		{URL: "https://example.com/page/2", Rel: []string{"next"}, Source: SourceHeader},
+		{URL: "https://example.com/x", Rel: []string{"alternate", "nofollow"}, Source: SourceHeader},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLinkHeader =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	header := []Link{{URL: "https://example.com/next", Rel: []string{"next"}}}
+	body := []Link{
+		{URL: "https://example.com/about", Source: SourceAnchor},
+		{URL: "https://example.com/canonical", Rel: []string{"canonical"}, Source: SourceLink},
+	}
+	if got, ok := Canonical(header, body); !ok || got != "https://example.com/canonical" {
+		t.Errorf("Canonical = (%q, %v); want (%q, true)", got, ok, "https://example.com/canonical")
+	}
+	if _, ok := Canonical(header); ok {
+		t.Error("Canonical without rel=canonical link returned ok=true")
+	}
+}
@@ -1,6 +1,7 @@
 package lrucache
 
 import (
+	"iter"
 	"sync"
 
 	dll "github.com/guilherme13c/go-search/utils/doubly-linked-list"
@@ -9,6 +10,7 @@ import (
 type LruCache[K comparable, V any] interface {
 	Get(K) (*V, bool)
 	Put(K, V)
+	All() iter.Seq2[K, V]
 }
 
 type lruCache[K comparable, V any] struct {
@@ -62,3 +64,22 @@ func (self *lruCache[K, V]) Put(key K, value V) {
 		delete(self.m, lru.Key)
 	}
 }
+
+func (self *lruCache[K, V]) All() iter.Seq2[K, V] {
+	self.mu.Lock()
+	keys := make([]K, 0, len(self.m))
+	values := make([]V, 0, len(self.m))
+	for node := self.l.Head.Next; node != self.l.Tail; node = node.Next {
+		keys = append(keys, node.Key)
+		values = append(values, node.Value)
+	}
+	self.mu.Unlock()
+
+	return func(yield func(K, V) bool) {
+		for i := range keys {
+			if !yield(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
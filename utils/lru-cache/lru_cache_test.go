@@ -1,6 +1,7 @@
 package lrucache
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -75,3 +76,33 @@ func TestEvictionOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestAllMostRecentFirst(t *testing.T) {
+	cache := NewLruCache[string, int](3)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3)
+	cache.Get("a")
+
+	var keys []string
+	var values []int
+	for k, v := range cache.All() {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	if got, want := strings.Join(keys, ","), "a,c,b"; got != want {
+		t.Errorf("All keys = %s; want %s", got, want)
+	}
+	if values[0] != 1 || values[1] != 3 || values[2] != 2 {
+		t.Errorf("All values = %v; want [1 3 2]", values)
+	}
+
+	for k := range cache.All() {
+		cache.Put(k+k, 0)
+		break
+	}
+	if _, ok := cache.Get("aa"); !ok {
+		t.Error("Put during iteration should not deadlock or be lost")
+	}
+}
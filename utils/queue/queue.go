@@ -9,6 +9,7 @@ type Queue[T any] interface {
 	Put(T)
 	Get() (T, bool)
 	Len() int
+	Items() []T
 }
 
 type randomizedQueue[T any] struct {
@@ -59,3 +60,10 @@ func (self *randomizedQueue[T]) Len() int {
 
 	return self.size
 }
+
+func (self *randomizedQueue[T]) Items() []T {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	return append([]T(nil), self.data...)
+}
@@ -77,3 +77,25 @@ func TestDeterministicRemovalOrder(t *testing.T) {
 	}
 }
 
+func TestItemsSnapshot(t *testing.T) {
+	q := NewQueue[int](0)
+	for i := range 3 {
+		q.Put(i)
+	}
+
+	items := q.Items()
+	if !reflect.DeepEqual(items, []int{0, 1, 2}) {
+		t.Errorf("Items = %v; want [0 1 2]", items)
+	}
+
+	items[0] = 42
+	q.Get()
+	if got := q.Len(); got != 2 {
+		t.Errorf("Len after Items and Get = %d; want 2", got)
+	}
+	for _, v := range q.Items() {
+		if v == 42 {
+			t.Error("modifying the Items snapshot changed the queue")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package recrawl
+
+import (
+	"math"
+	"time"
+)
+
+// Observation is one revisit: the time since the previous fetch and whether
+// the content hash differed from it.
+type Observation struct {
+	Interval time.Duration
+	Changed  bool
+}
+
+// EstimateRate returns the maximum likelihood estimate of a page's Poisson
+// change rate, in changes per second, from revisits at irregular intervals.
+// A revisit only shows whether at least one change happened, so the likelihood
+// of a change seen after t is 1-e^(-λt) and of none is e^(-λt). A page never
+// seen to change gets 0; a page changed at every visit has no finite estimate
+// and gets log(2n+1) changes per mean interval, the bias-reduced estimator of
+// Cho and Garcia-Molina for that case.
+func EstimateRate(obs []Observation) (float64, bool) {
+	var changed, total float64
+	var unchangedTime float64
+	for _, o := range obs {
+		t := o.Interval.Seconds()
+		if t <= 0 {
+			continue
+		}
+		total += t
+		if o.Changed {
+			changed++
+		} else {
+			unchangedTime += t
+		}
+	}
+	n := float64(len(obs))
+	if total == 0 {
+		return 0, false
+	}
+	if changed == 0 {
+		return 0, true
+	}
+	if unchangedTime == 0 {
+		return math.Log(2*n+1) / (total / n), true
+	}
+
+	// The score Σchanged t/(e^(λt)-1) - Σunchanged t falls monotonically
+	// in λ, so the root is bracketed and found by bisection in log space.
+	score := func(rate float64) float64 {
+		s := -unchangedTime
+		for _, o := range obs {
+			if t := o.Interval.Seconds(); o.Changed && t > 0 {
+				s += t / math.Expm1(rate*t)
+			}
+		}
+		return s
+	}
+	lo, hi := 1e-12, 1.0
+	for score(hi) > 0 {
+		hi *= 2
+	}
+	for range 100 {
+		mid := math.Sqrt(lo * hi)
+		if score(mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+		if hi/lo < 1+1e-9 {
+			break
+		}
+	}
+
+	return math.Sqrt(lo * hi), true
+}
+
+// Freshness is the expected fraction of time a copy of a page changing at rate
+// is up to date when it is fetched every interval.
+func Freshness(rate float64, interval time.Duration) float64 {
+	x := rate * interval.Seconds()
+	if x == 0 {
+		return 1
+	}
+	return -math.Expm1(-x) / x
+}
+
+// gain is the marginal freshness of revisiting faster, ∂F/∂f for visit
+// frequency f, written in terms of x = rate/f: (1-(1+x)e^(-x))/rate.
+func gain(x float64) float64 {
+	return -math.Expm1(-x) - x*math.Exp(-x)
+}
+
+// frequency returns the visit frequency at which a page's marginal freshness
+// equals mu, or 0 when even never revisiting it gains less per fetch than mu.
+func frequency(rate, mu float64) float64 {
+	target := mu * rate
+	if rate <= 0 || target >= 1 {
+		return 0
+	}
+
+	lo, hi := 0.0, 1.0
+	for gain(hi) < target {
+		hi *= 2
+	}
+	for range 64 {
+		mid := (lo + hi) / 2
+		if gain(mid) < target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return rate / ((lo + hi) / 2)
+}
+
+// allocate spreads budget visits per second over pages with the given change
+// rates so that their average freshness is maximal (Cho and Garcia-Molina,
+// "Synchronizing a database to improve freshness"). At the optimum every
+// revisited page has the same marginal freshness mu, so mu is searched for
+// until the frequencies add up to the budget. Pages changing too fast to keep
+// fresh get 0.
+func allocate(rates []float64, budget float64) []float64 {
+	res := make([]float64, len(rates))
+
+	mu, ok := marginal(rates, budget)
+	if !ok {
+		return res
+	}
+	for i, rate := range rates {
+		res[i] = frequency(rate, mu)
+	}
+
+	return res
+}
+
+// marginal returns the marginal freshness mu at which the frequencies of pages
+// with the given rates add up to budget, or false when there is nothing to
+// allocate.
+func marginal(rates []float64, budget float64) (float64, bool) {
+	minRate := math.Inf(1)
+	for _, rate := range rates {
+		if rate > 0 {
+			minRate = min(minRate, rate)
+		}
+	}
+	if budget <= 0 || math.IsInf(minRate, 1) {
+		return 0, false
+	}
+
+	total := func(mu float64) float64 {
+		sum := 0.0
+		for _, rate := range rates {
+			sum += frequency(rate, mu)
+		}
+		return sum
+	}
+	hi := 1 / minRate
+	lo := hi
+	for range 200 {
+		if total(lo) >= budget {
+			break
+		}
+		lo /= 2
+	}
+	for range 60 {
+		mid := math.Sqrt(lo * hi)
+		if total(mid) > budget {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return hi, true
+}
@@ -0,0 +1,177 @@
+package recrawl
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// simulate visits a page whose changes form a Poisson process with the given
+// rate per hour, at random intervals averaging meanInterval.
+func simulate(r *rand.Rand, ratePerHour float64, meanInterval time.Duration, visits int) []Observation {
+	res := []Observation{}
+	for range visits {
+		interval := time.Duration((0.5 + r.Float64()) * float64(meanInterval))
+		p := -math.Expm1(-ratePerHour * interval.Hours())
+		res = append(res, Observation{Interval: interval, Changed: r.Float64() < p})
+	}
+	return res
+}
+
+func TestEstimateRate(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for _, ratePerHour := range []float64{0.05, 0.5, 2} {
+		obs := simulate(r, ratePerHour, time.Hour, 2000)
+		got, ok := EstimateRate(obs)
+		got *= 3600
+		if !ok || math.Abs(got-ratePerHour)/ratePerHour > 0.15 {
+			t.Errorf("EstimateRate for %v/h = %v/h (ok %v); want within 15%%", ratePerHour, got, ok)
+		}
+	}
+
+	if _, ok := EstimateRate(nil); ok {
+		t.Error("EstimateRate(nil) ok = true; want no estimate")
+	}
+
+	never := []Observation{{time.Hour, false}, {2 * time.Hour, false}}
+	if got, ok := EstimateRate(never); !ok || got != 0 {
+		t.Errorf("EstimateRate(never changed) = (%v, %v); want (0, true)", got, ok)
+	}
+
+	always := []Observation{{time.Hour, true}, {time.Hour, true}, {time.Hour, true}}
+	got, ok := EstimateRate(always)
+	if want := math.Log(7) / 3600; !ok || math.Abs(got-want) > 1e-12 {
+		t.Errorf("EstimateRate(always changed) = %v; want %v", got, want)
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	if f := Freshness(0, time.Hour); f != 1 {
+		t.Errorf("Freshness(never changes) = %v; want 1", f)
+	}
+	daily := 1.0 / 86400
+	if Freshness(daily, time.Hour) <= Freshness(daily, 24*time.Hour) {
+		t.Error("Freshness should fall as the revisit interval grows")
+	}
+	if f := Freshness(daily, 24*time.Hour); math.Abs(f-(1-math.Exp(-1))) > 1e-9 {
+		t.Errorf("Freshness(rate 1/day, daily) = %v; want 1-1/e", f)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	perDay := func(n float64) float64 { return n / 86400 }
+	rates := []float64{0, perDay(0.1), perDay(1), perDay(10), perDay(10000)}
+	budget := perDay(20)
+
+	freqs := allocate(rates, budget)
+
+	sum := 0.0
+	for _, f := range freqs {
+		sum += f
+	}
+	if math.Abs(sum-budget)/budget > 1e-3 {
+		t.Errorf("allocated %v visits/day; want the budget of 20", sum*86400)
+	}
+	if freqs[0] != 0 {
+		t.Errorf("page that never changes got %v visits/day; want 0", freqs[0]*86400)
+	}
+	if !(freqs[1] < freqs[2] && freqs[2] < freqs[3]) {
+		t.Errorf("frequencies %v should grow with the change rate", freqs)
+	}
+	if freqs[4] != 0 {
+		t.Errorf("page changing 10000 times a day got %v visits/day; want 0 (cannot be kept fresh)", freqs[4]*86400)
+	}
+
+	// The allocation beats spreading the same budget evenly.
+	optimal, uniform := 0.0, 0.0
+	for i, rate := range rates {
+		if freqs[i] > 0 {
+			optimal += Freshness(rate, time.Duration(float64(time.Second)/freqs[i]))
+		}
+		uniform += Freshness(rate, time.Duration(float64(time.Second)*float64(len(rates))/budget))
+	}
+	if optimal < uniform-1 {
+		t.Errorf("optimal freshness %v below uniform %v", optimal, uniform)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewScheduler(Options{
+		Budget:          2.0 / 3600,
+		MinInterval:     time.Minute,
+		MaxInterval:     30 * 24 * time.Hour,
+		InitialInterval: time.Hour,
+	})
+
+	s.Observe("https://a.example/", start, "sha1:A")
+	s.Observe("https://b.example/", start, "sha1:B")
+	if due := s.Due(start.Add(30 * time.Minute)); len(due) != 0 {
+		t.Fatalf("Due before the initial interval = %v; want none", due)
+	}
+
+	// a changes at every visit, b never does.
+	at := start
+	for i := range 10 {
+		at = at.Add(time.Hour)
+		s.Observe("https://a.example/", at, "sha1:A"+string(rune('0'+i)))
+		s.Observe("https://b.example/", at, "sha1:B")
+	}
+	s.Plan()
+
+	var due []string
+	when := at
+	for len(due) == 0 {
+		when = when.Add(time.Minute)
+		due = s.Due(when)
+	}
+	if len(due) != 1 || due[0] != "https://a.example/" {
+		t.Fatalf("first Due = %v after %v; want only the changing page", due, when.Sub(at))
+	}
+	if again := s.Due(when); len(again) != 0 {
+		t.Errorf("Due twice = %v; want pages handed out once", again)
+	}
+
+	history := s.Snapshot()
+	restored := NewScheduler(Options{Budget: 2.0 / 3600, MinInterval: time.Minute, MaxInterval: 30 * 24 * time.Hour, InitialInterval: time.Hour})
+	restored.Restore(history)
+	if restored.Len() != 2 {
+		t.Fatalf("restored Len = %d; want 2", restored.Len())
+	}
+	restored.Plan()
+	if due := restored.Due(at.Add(31 * 24 * time.Hour)); len(due) != 2 || due[0] != "https://a.example/" {
+		t.Errorf("restored Due after MaxInterval = %v; want both, changing page first", due)
+	}
+}
+
+func TestScheduler_PlansBetweenDivisions(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewScheduler(Options{
+		Budget:          20.0 / 3600,
+		MinInterval:     time.Minute,
+		MaxInterval:     30 * 24 * time.Hour,
+		InitialInterval: time.Hour,
+	})
+
+	for i := range 100 {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		s.Observe(url, start, "sha1:same")
+		s.Observe(url, start.Add(time.Hour), "sha1:same")
+	}
+	s.Plan()
+
+	// A page found after the division is too few to divide the budget
+	// again, but it gets its share of the last one rather than the
+	// initial interval, which the budget cannot afford for every page.
+	s.Observe("https://example.com/new", start.Add(time.Hour), "sha1:new")
+	s.Plan()
+	if due := s.Due(start.Add(2*time.Hour + time.Minute)); len(due) != 0 {
+		t.Errorf("Due after the initial interval = %v; want none within the budget", due)
+	}
+	if due := s.Due(start.Add(31 * 24 * time.Hour)); len(due) != 101 {
+		t.Errorf("Due after MaxInterval = %d pages; want all 101", len(due))
+	}
+}
@@ -0,0 +1,279 @@
+package recrawl
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxObservations bounds the change history kept per URL, so the estimate
+// follows pages whose behaviour changes over time.
+const maxObservations = 64
+
+// replanShare is the share of pages that must have been observed since the
+// budget was last divided before Plan divides it again. In between, pages are
+// planned against the marginal freshness of the last division, which moves
+// little while most estimates stay put.
+const replanShare = 0.1
+
+type Options struct {
+	// Budget is the number of revisits per second shared by all URLs.
+	Budget      float64
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// InitialInterval is used for URLs fetched only once, whose change rate
+	// is not known yet.
+	InitialInterval time.Duration
+}
+
+type PageHistory struct {
+	URL          string
+	LastFetch    time.Time
+	Digest       string
+	Observations []Observation
+	NextVisit    time.Time
+}
+
+type Scheduler interface {
+	Observe(url string, at time.Time, digest string)
+	Plan()
+	Due(now time.Time) []string
+	Len() int
+	Snapshot() []PageHistory
+	Restore(history []PageHistory)
+}
+
+type page struct {
+	PageHistory
+	rate     float64
+	known    bool
+	interval time.Duration
+	index    int
+}
+
+type pageHeap []*page
+
+func (self pageHeap) Len() int { return len(self) }
+
+func (self pageHeap) Less(i, j int) bool { return self[i].NextVisit.Before(self[j].NextVisit) }
+
+func (self pageHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].index = i
+	self[j].index = j
+}
+
+func (self *pageHeap) Push(x any) {
+	p := x.(*page)
+	p.index = len(*self)
+	*self = append(*self, p)
+}
+
+func (self *pageHeap) Pop() any {
+	old := *self
+	p := old[len(old)-1]
+	*self = old[:len(old)-1]
+	return p
+}
+
+type scheduler struct {
+	mu       sync.Mutex
+	opts     Options
+	pages    map[string]*page
+	queue    pageHeap
+	marginal float64
+	planned  bool
+	observed int
+}
+
+func NewScheduler(opts Options) Scheduler {
+	return &scheduler{
+		mu:    sync.Mutex{},
+		opts:  opts,
+		pages: make(map[string]*page),
+		queue: pageHeap{},
+	}
+}
+
+// Observe records a fetch of url whose content hashed to digest. Once the
+// budget has been divided, the page's interval follows its new estimate right
+// away; before that it keeps the initial interval.
+func (self *scheduler) Observe(url string, at time.Time, digest string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	p, ok := self.pages[url]
+	if !ok {
+		p = &page{PageHistory: PageHistory{URL: url}, interval: self.opts.InitialInterval}
+		self.pages[url] = p
+		heap.Push(&self.queue, p)
+	} else if at.After(p.LastFetch) {
+		p.Observations = append(p.Observations, Observation{
+			Interval: at.Sub(p.LastFetch),
+			Changed:  digest != p.Digest,
+		})
+		if len(p.Observations) > maxObservations {
+			p.Observations = slices.Delete(p.Observations, 0, len(p.Observations)-maxObservations)
+		}
+		p.rate, p.known = self.estimate(p.Observations)
+	}
+	if self.planned {
+		p.interval = self.interval(p)
+	}
+	p.LastFetch = at
+	p.Digest = digest
+	p.NextVisit = at.Add(p.interval)
+	heap.Fix(&self.queue, p.index)
+	self.observed++
+}
+
+// estimate smooths a page's change rate with one pseudo-observation of a
+// change per InitialInterval, so that a few unchanged visits lengthen the
+// interval gradually instead of declaring the page static.
+func (self *scheduler) estimate(obs []Observation) (float64, bool) {
+	if len(obs) == 0 {
+		return 0, false
+	}
+	if self.opts.InitialInterval > 0 {
+		obs = append([]Observation{{Interval: self.opts.InitialInterval, Changed: true}}, obs...)
+	}
+
+	return EstimateRate(obs)
+}
+
+// Plan re-divides the budget using the current change estimates once enough
+// of them have changed since the last division. URLs without an estimate are
+// planned as if they changed once per InitialInterval.
+func (self *scheduler) Plan() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.observed == 0 || (self.planned && float64(self.observed) < replanShare*float64(len(self.pages))) {
+		return
+	}
+	self.observed = 0
+
+	rates := make([]float64, len(self.queue))
+	for i, p := range self.queue {
+		rates[i] = self.rate(p)
+	}
+	self.marginal, _ = marginal(rates, self.opts.Budget)
+	self.planned = true
+
+	for _, p := range self.queue {
+		p.interval = self.interval(p)
+		p.NextVisit = p.LastFetch.Add(p.interval)
+	}
+	heap.Init(&self.queue)
+}
+
+// rate is the page's estimated change rate, or the prior of one change per
+// InitialInterval when it has none yet.
+func (self *scheduler) rate(p *page) float64 {
+	if p.known {
+		return p.rate
+	}
+	if self.opts.InitialInterval > 0 {
+		return 1 / self.opts.InitialInterval.Seconds()
+	}
+
+	return 0
+}
+
+// interval turns the page's share of the last division into the time between
+// its visits.
+func (self *scheduler) interval(p *page) time.Duration {
+	f := 0.0
+	if self.marginal > 0 {
+		f = frequency(self.rate(p), self.marginal)
+	}
+
+	res := self.opts.MaxInterval
+	if f > 0 {
+		res = time.Duration(math.Min(1/f*float64(time.Second), float64(math.MaxInt64)))
+	}
+	res = max(res, self.opts.MinInterval)
+	if self.opts.MaxInterval > 0 {
+		res = min(res, self.opts.MaxInterval)
+	}
+
+	return res
+}
+
+// Due returns the URLs whose visit time has come, most likely stale first, and
+// pushes each one's next visit back by its interval so it is not handed out
+// twice before it has been fetched.
+func (self *scheduler) Due(now time.Time) []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	due := []*page{}
+	for len(self.queue) > 0 && !self.queue[0].NextVisit.After(now) {
+		due = append(due, heap.Pop(&self.queue).(*page))
+	}
+
+	stale := func(p *page) float64 {
+		return -math.Expm1(-self.rate(p) * now.Sub(p.LastFetch).Seconds())
+	}
+	slices.SortStableFunc(due, func(a, b *page) int {
+		sa, sb := stale(a), stale(b)
+		switch {
+		case sa > sb:
+			return -1
+		case sa < sb:
+			return 1
+		}
+		return a.NextVisit.Compare(b.NextVisit)
+	})
+
+	res := make([]string, len(due))
+	for i, p := range due {
+		res[i] = p.URL
+		p.NextVisit = now.Add(p.interval)
+		heap.Push(&self.queue, p)
+	}
+
+	return res
+}
+
+func (self *scheduler) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.pages)
+}
+
+func (self *scheduler) Snapshot() []PageHistory {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make([]PageHistory, 0, len(self.queue))
+	for _, p := range self.queue {
+		history := p.PageHistory
+		history.Observations = slices.Clone(p.Observations)
+		res = append(res, history)
+	}
+
+	return res
+}
+
+func (self *scheduler) Restore(history []PageHistory) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, h := range history {
+		if p, ok := self.pages[h.URL]; ok {
+			heap.Remove(&self.queue, p.index)
+		}
+		p := &page{PageHistory: h, interval: self.opts.InitialInterval}
+		if !h.LastFetch.IsZero() && h.NextVisit.After(h.LastFetch) {
+			p.interval = h.NextVisit.Sub(h.LastFetch)
+		}
+		p.rate, p.known = self.estimate(h.Observations)
+		self.pages[h.URL] = p
+		heap.Push(&self.queue, p)
+	}
+	self.observed += len(history)
+}
@@ -0,0 +1,184 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type answer struct {
+	addrs []netip.Addr
+	ttl   time.Duration
+	// soaTTL is the negative caching TTL from the authority section, or -1
+	// when the server did not send one.
+	soaTTL time.Duration
+}
+
+func canonicalName(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".") + "."
+}
+
+func (self *resolver) resolve(ctx context.Context, server, name string) (answer, error) {
+	res := answer{ttl: -1, soaTTL: -1}
+
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		msg, err := self.exchange(ctx, server, name, qtype)
+		if err != nil {
+			if len(res.addrs) > 0 {
+				break
+			}
+			return res, err
+		}
+
+		switch msg.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			res.soaTTL = negativeTTL(msg)
+			return res, nil
+		default:
+			if len(res.addrs) > 0 {
+				return res, nil
+			}
+			return res, &net.DNSError{Err: "server misbehaving: " + msg.RCode.String(), Name: name, Server: server, IsTemporary: true}
+		}
+
+		for _, rr := range msg.Answers {
+			ttl := time.Duration(rr.Header.TTL) * time.Second
+			if res.ttl < 0 || ttl < res.ttl {
+				res.ttl = ttl
+			}
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				res.addrs = append(res.addrs, netip.AddrFrom4(body.A))
+			case *dnsmessage.AAAAResource:
+				res.addrs = append(res.addrs, netip.AddrFrom16(body.AAAA))
+			}
+		}
+		if len(msg.Answers) == 0 {
+			res.soaTTL = negativeTTL(msg)
+		}
+	}
+
+	return res, nil
+}
+
+// RFC 2308: a negative answer is cached for the lesser of the SOA record's
+// TTL and its MINIMUM field.
+func negativeTTL(msg dnsmessage.Message) time.Duration {
+	for _, rr := range msg.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			return time.Duration(min(rr.Header.TTL, soa.MinTTL)) * time.Second
+		}
+	}
+	return -1
+}
+
+func (self *resolver) exchange(ctx context.Context, server, name string, qtype dnsmessage.Type) (dnsmessage.Message, error) {
+	if self.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.opts.Timeout)
+		defer cancel()
+	}
+
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return dnsmessage.Message{}, &net.DNSError{Err: "invalid name", Name: name}
+	}
+	question := dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{question},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, fmt.Errorf("pack query error: %w", err)
+	}
+
+	msg, err := self.exchangeUDP(ctx, server, packed, query.Header.ID, question)
+	if err == nil && msg.Truncated {
+		msg, err = self.exchangeTCP(ctx, server, packed, query.Header.ID, question)
+	}
+	if err != nil {
+		return dnsmessage.Message{}, &net.DNSError{Err: err.Error(), Name: name, Server: server, IsTimeout: ctx.Err() != nil, IsTemporary: true}
+	}
+
+	return msg, nil
+}
+
+func (self *resolver) exchangeUDP(ctx context.Context, server string, query []byte, id uint16, question dnsmessage.Question) (dnsmessage.Message, error) {
+	conn, err := self.dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+		// Replies that do not match the query are ignored rather than
+		// trusted, as a spoofed packet would be.
+		var msg dnsmessage.Message
+		if msg.Unpack(buf[:n]) == nil && matches(msg, id, question) {
+			return msg, nil
+		}
+	}
+}
+
+func (self *resolver) exchangeTCP(ctx context.Context, server string, query []byte, id uint16, question dnsmessage.Question) (dnsmessage.Message, error) {
+	conn, err := self.dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf); err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if !matches(msg, id, question) {
+		return dnsmessage.Message{}, fmt.Errorf("mismatched DNS reply from %s", server)
+	}
+
+	return msg, nil
+}
+
+func matches(msg dnsmessage.Message, id uint16, question dnsmessage.Question) bool {
+	return msg.Response && msg.ID == id && len(msg.Questions) == 1 &&
+		msg.Questions[0].Type == question.Type &&
+		strings.EqualFold(msg.Questions[0].Name.String(), question.Name.String())
+}
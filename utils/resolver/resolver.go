@@ -0,0 +1,198 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	lrucache "github.com/guilherme13c/go-search/utils/lru-cache"
+)
+
+type Options struct {
+	// Servers are "ip:port" addresses queried in order. When empty the
+	// nameservers of /etc/resolv.conf are used.
+	Servers []string
+	// Timeout bounds each DNS query; DialTimeout bounds connecting to the
+	// resolved address.
+	Timeout     time.Duration
+	DialTimeout time.Duration
+	CacheSize   uint
+	MinTTL      time.Duration
+	MaxTTL      time.Duration
+	NegativeTTL time.Duration
+}
+
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) ([]netip.Addr, error)
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+type entry struct {
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+}
+
+type lookup struct {
+	done chan struct{}
+	res  entry
+}
+
+type resolver struct {
+	opts    Options
+	hosts   map[string][]netip.Addr
+	dialer  net.Dialer
+	now     func() time.Time
+	mu      sync.Mutex
+	cache   lrucache.LruCache[string, entry]
+	pending map[string]*lookup
+}
+
+func NewResolver(opts Options) (Resolver, error) {
+	if len(opts.Servers) == 0 {
+		servers, err := readResolvConf(resolvConfPath)
+		if err != nil {
+			return nil, err
+		}
+		opts.Servers = servers
+	}
+	if len(opts.Servers) == 0 {
+		return nil, errors.New("no DNS servers configured")
+	}
+
+	hosts, err := readHosts(hostsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolver{
+		opts:    opts,
+		hosts:   hosts,
+		dialer:  net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second},
+		now:     time.Now,
+		mu:      sync.Mutex{},
+		cache:   lrucache.NewLruCache[string, entry](max(opts.CacheSize, 1)),
+		pending: make(map[string]*lookup),
+	}, nil
+}
+
+// LookupIP returns the IPv4 and then IPv6 addresses of host. Answers are
+// cached for their TTL and "no such host" answers for the zone's negative TTL;
+// server failures are not cached. Concurrent lookups of one host share a
+// single query.
+func (self *resolver) LookupIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	name := canonicalName(host)
+	if addrs, ok := self.hosts[name]; ok {
+		return addrs, nil
+	}
+
+	self.mu.Lock()
+	if cached, ok := self.cache.Get(name); ok && self.now().Before(cached.expires) {
+		self.mu.Unlock()
+		return cached.addrs, cached.err
+	}
+	l, running := self.pending[name]
+	if !running {
+		l = &lookup{done: make(chan struct{})}
+		self.pending[name] = l
+	}
+	self.mu.Unlock()
+
+	if running {
+		select {
+		case <-l.done:
+			return l.res.addrs, l.res.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// The query is detached from ctx so that one impatient caller cannot
+	// fail the lookup for everyone waiting on it.
+	l.res = self.query(context.WithoutCancel(ctx), host, name)
+
+	self.mu.Lock()
+	delete(self.pending, name)
+	if !l.res.expires.IsZero() {
+		self.cache.Put(name, l.res)
+	}
+	self.mu.Unlock()
+	close(l.done)
+
+	return l.res.addrs, l.res.err
+}
+
+func (self *resolver) query(ctx context.Context, host, name string) entry {
+	var lastErr error
+	for _, server := range self.opts.Servers {
+		ans, err := self.resolve(ctx, server, name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if len(ans.addrs) == 0 {
+			ttl := self.opts.NegativeTTL
+			if ans.soaTTL >= 0 {
+				ttl = min(ans.soaTTL, self.opts.NegativeTTL)
+			}
+			return entry{
+				err:     &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true},
+				expires: self.now().Add(max(ttl, self.opts.MinTTL)),
+			}
+		}
+
+		ttl := max(ans.ttl, self.opts.MinTTL)
+		if self.opts.MaxTTL > 0 {
+			ttl = min(ttl, self.opts.MaxTTL)
+		}
+		return entry{addrs: ans.addrs, expires: self.now().Add(ttl)}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(lastErr, &dnsErr) {
+		return entry{err: dnsErr}
+	}
+	return entry{err: &net.DNSError{Err: fmt.Sprint(lastErr), Name: host, IsTemporary: true}}
+}
+
+// DialContext resolves address through the cache and tries its IPs in turn.
+func (self *resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("dial error: %w", err)
+	}
+
+	addrs, err := self.LookupIP(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		switch {
+		case network == "tcp4" && !addr.Is4(), network == "tcp6" && !addr.Is6():
+			continue
+		}
+		conn, err := self.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no %s address for %s", network, host)}
+	}
+
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,338 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type zone struct {
+	a        map[string][]netip.Addr
+	ttl      uint32
+	servfail map[string]bool
+	truncate map[string]bool
+	delay    time.Duration
+	queries  atomic.Int64
+}
+
+func (self *zone) reply(query []byte, tcp bool) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+		return nil
+	}
+	self.queries.Add(1)
+	time.Sleep(self.delay)
+
+	q := msg.Questions[0]
+	name := q.Name.String()
+	res := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+	switch {
+	case self.servfail[name]:
+		res.RCode = dnsmessage.RCodeServerFailure
+	case self.truncate[name] && !tcp:
+		res.Truncated = true
+	case self.a[name] == nil:
+		res.RCode = dnsmessage.RCodeNameError
+		res.Authorities = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("test."), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 3600},
+			Body: &dnsmessage.SOAResource{
+				NS: dnsmessage.MustNewName("ns.test."), MBox: dnsmessage.MustNewName("admin.test."),
+				MinTTL: 60,
+			},
+		}}
+	default:
+		for _, addr := range self.a[name] {
+			header := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: self.ttl}
+			switch {
+			case q.Type == dnsmessage.TypeA && addr.Is4():
+				header.Type = dnsmessage.TypeA
+				res.Answers = append(res.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: addr.As4()}})
+			case q.Type == dnsmessage.TypeAAAA && addr.Is6():
+				header.Type = dnsmessage.TypeAAAA
+				res.Answers = append(res.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+			}
+		}
+	}
+
+	packed, _ := res.Pack()
+	return packed
+}
+
+// serve runs z on a UDP and a TCP listener sharing one port.
+func serve(t *testing.T, z *zone) string {
+	t.Helper()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp error = %v", err)
+	}
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("listen tcp error = %v", err)
+	}
+	t.Cleanup(func() {
+		udp.Close()
+		tcp.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			go func() {
+				if res := z.reply(query, false); res != nil {
+					udp.WriteTo(res, addr)
+				}
+			}()
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				res := z.reply(query, true)
+				conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(res))), res...))
+			}()
+		}
+	}()
+
+	return udp.LocalAddr().String()
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (self *clock) Now() time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.now
+}
+
+func (self *clock) Advance(d time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.now = self.now.Add(d)
+}
+
+func newTestResolver(t *testing.T, z *zone, opts Options) (*resolver, *clock) {
+	t.Helper()
+
+	opts.Servers = []string{serve(t, z)}
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	r, err := NewResolver(opts)
+	if err != nil {
+		t.Fatalf("NewResolver error = %v", err)
+	}
+	c := &clock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.(*resolver).now = c.Now
+	return r.(*resolver), c
+}
+
+func TestLookupIP_CachesForTTL(t *testing.T) {
+	z := &zone{
+		a: map[string][]netip.Addr{
+			"www.example.test.": {netip.MustParseAddr("192.0.2.10"), netip.MustParseAddr("2001:db8::10")},
+		},
+		ttl: 300,
+	}
+	r, c := newTestResolver(t, z, Options{CacheSize: 16, NegativeTTL: time.Hour})
+
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.10"), netip.MustParseAddr("2001:db8::10")}
+	for range 3 {
+		addrs, err := r.LookupIP(context.Background(), "WWW.example.test")
+		if err != nil || len(addrs) != 2 || addrs[0] != want[0] || addrs[1] != want[1] {
+			t.Fatalf("LookupIP = (%v, %v); want %v", addrs, err, want)
+		}
+	}
+	if n := z.queries.Load(); n != 2 {
+		t.Errorf("queries after cached lookups = %d; want 2 (A and AAAA)", n)
+	}
+
+	c.Advance(301 * time.Second)
+	if _, err := r.LookupIP(context.Background(), "www.example.test"); err != nil {
+		t.Fatalf("LookupIP after expiry error = %v", err)
+	}
+	if n := z.queries.Load(); n != 4 {
+		t.Errorf("queries after TTL expiry = %d; want 4", n)
+	}
+}
+
+func TestLookupIP_TTLBounds(t *testing.T) {
+	z := &zone{a: map[string][]netip.Addr{"a.test.": {netip.MustParseAddr("192.0.2.1")}}, ttl: 86400}
+	r, c := newTestResolver(t, z, Options{CacheSize: 16, MaxTTL: time.Minute})
+
+	r.LookupIP(context.Background(), "a.test")
+	c.Advance(2 * time.Minute)
+	r.LookupIP(context.Background(), "a.test")
+	if n := z.queries.Load(); n != 4 {
+		t.Errorf("queries = %d; want the day-long TTL capped at MaxTTL", n)
+	}
+}
+
+func TestLookupIP_NegativeCaching(t *testing.T) {
+	z := &zone{}
+	r, c := newTestResolver(t, z, Options{CacheSize: 16, NegativeTTL: time.Hour})
+
+	for range 2 {
+		_, err := r.LookupIP(context.Background(), "missing.test")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Fatalf("LookupIP(missing) error = %v; want not found", err)
+		}
+	}
+	if n := z.queries.Load(); n != 1 {
+		t.Errorf("queries = %d; want 1 (NXDOMAIN cached)", n)
+	}
+
+	// The SOA MINIMUM of 60s bounds the negative TTL.
+	c.Advance(61 * time.Second)
+	r.LookupIP(context.Background(), "missing.test")
+	if n := z.queries.Load(); n != 2 {
+		t.Errorf("queries after negative TTL = %d; want 2", n)
+	}
+}
+
+func TestLookupIP_ServerFailureNotCached(t *testing.T) {
+	z := &zone{servfail: map[string]bool{"broken.test.": true}}
+	r, _ := newTestResolver(t, z, Options{CacheSize: 16, NegativeTTL: time.Hour})
+
+	for range 2 {
+		_, err := r.LookupIP(context.Background(), "broken.test")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.Temporary() {
+			t.Fatalf("LookupIP(servfail) error = %v; want temporary DNS error", err)
+		}
+	}
+	if n := z.queries.Load(); n != 2 {
+		t.Errorf("queries = %d; want 2 (failures are not cached)", n)
+	}
+}
+
+func TestLookupIP_TruncatedFallsBackToTCP(t *testing.T) {
+	z := &zone{
+		a:        map[string][]netip.Addr{"big.test.": {netip.MustParseAddr("192.0.2.7")}},
+		ttl:      60,
+		truncate: map[string]bool{"big.test.": true},
+	}
+	r, _ := newTestResolver(t, z, Options{CacheSize: 16})
+
+	addrs, err := r.LookupIP(context.Background(), "big.test")
+	if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.7") {
+		t.Errorf("LookupIP(truncated) = (%v, %v); want [192.0.2.7]", addrs, err)
+	}
+}
+
+func TestLookupIP_SharesConcurrentQueries(t *testing.T) {
+	z := &zone{
+		a:     map[string][]netip.Addr{"busy.test.": {netip.MustParseAddr("192.0.2.5")}},
+		ttl:   60,
+		delay: 50 * time.Millisecond,
+	}
+	r, _ := newTestResolver(t, z, Options{CacheSize: 16})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.LookupIP(context.Background(), "busy.test"); err != nil {
+				t.Errorf("LookupIP error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := z.queries.Load(); n != 2 {
+		t.Errorf("queries = %d; want 2 for 20 concurrent lookups", n)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	z := &zone{a: map[string][]netip.Addr{"site.test.": {netip.MustParseAddr("127.0.0.1")}}, ttl: 60}
+	r, _ := newTestResolver(t, z, Options{CacheSize: 16})
+
+	client := &http.Client{Transport: &http.Transport{DialContext: r.DialContext}}
+	resp, err := client.Get("http://site.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("Get through resolver error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("body = %q; want ok", body)
+	}
+
+	if _, err := r.DialContext(context.Background(), "tcp", "missing.test:80"); err == nil {
+		t.Error("DialContext(missing) error = nil; want error")
+	}
+}
+
+func TestReadHostsAndResolvConf(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	os.WriteFile(hosts, []byte("# comment\n127.0.0.1 localhost Local.Test # alias\n::1 localhost\nbogus line\n"), 0o644)
+	resolvConf := filepath.Join(dir, "resolv.conf")
+	os.WriteFile(resolvConf, []byte("search example.com\nnameserver 10.0.0.53\nnameserver 2001:db8::53\noptions ndots:1\n"), 0o644)
+
+	h, err := readHosts(hosts)
+	if err != nil {
+		t.Fatalf("readHosts error = %v", err)
+	}
+	if got := h["localhost."]; len(got) != 2 || got[1] != netip.MustParseAddr("::1") {
+		t.Errorf("hosts[localhost.] = %v; want [127.0.0.1 ::1]", got)
+	}
+	if got := h["local.test."]; len(got) != 1 {
+		t.Errorf("hosts[local.test.] = %v; want one address", got)
+	}
+
+	servers, err := readResolvConf(resolvConf)
+	if err != nil || len(servers) != 2 || servers[0] != "10.0.0.53:53" || servers[1] != "[2001:db8::53]:53" {
+		t.Errorf("readResolvConf = (%v, %v); want [10.0.0.53:53 [2001:db8::53]:53]", servers, err)
+	}
+
+	if h, err := readHosts(filepath.Join(dir, "missing")); err != nil || len(h) != 0 {
+		t.Errorf("readHosts(missing) = (%v, %v); want empty", h, err)
+	}
+}
@@ -0,0 +1,109 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+type Breaker interface {
+	Allow(host string, now time.Time) (time.Time, bool)
+	Success(host string)
+	Failure(host string, now time.Time) bool
+	Snapshot() []HostState
+	Restore(hosts []HostState)
+}
+
+// HostState is a host's run of failures and, once its circuit has opened,
+// when it may be tried again.
+type HostState struct {
+	Host      string
+	Failures  int
+	OpenUntil time.Time
+}
+
+type hostState struct {
+	failures  int
+	openUntil time.Time
+}
+
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*hostState
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) Breaker {
+	return &breaker{
+		mu:        sync.Mutex{},
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// Allow reports whether a request to host may go ahead. While the circuit is
+// open it returns the time at which to try again. Once the cooldown has passed
+// one probe is let through per cooldown period until Success closes the
+// circuit.
+func (self *breaker) Allow(host string, now time.Time) (time.Time, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	state, ok := self.hosts[host]
+	if !ok || state.failures < self.threshold {
+		return time.Time{}, true
+	}
+	if now.Before(state.openUntil) {
+		return state.openUntil, false
+	}
+	state.openUntil = now.Add(self.cooldown)
+
+	return time.Time{}, true
+}
+
+func (self *breaker) Success(host string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	delete(self.hosts, host)
+}
+
+func (self *breaker) Failure(host string, now time.Time) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	state, ok := self.hosts[host]
+	if !ok {
+		state = &hostState{}
+		self.hosts[host] = state
+	}
+	state.failures++
+	if state.failures < self.threshold {
+		return false
+	}
+	state.openUntil = now.Add(self.cooldown)
+
+	return true
+}
+
+func (self *breaker) Snapshot() []HostState {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res := make([]HostState, 0, len(self.hosts))
+	for host, state := range self.hosts {
+		res = append(res, HostState{Host: host, Failures: state.failures, OpenUntil: state.openUntil})
+	}
+
+	return res
+}
+
+func (self *breaker) Restore(hosts []HostState) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, host := range hosts {
+		self.hosts[host.Host] = &hostState{failures: host.Failures, openUntil: host.OpenUntil}
+	}
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Transient
+	Permanent
+)
+
+func (self Outcome) String() string {
+	switch self {
+	case Success:
+		return "success"
+	case Transient:
+		return "transient"
+	case Permanent:
+		return "permanent"
+	}
+	return "unknown"
+}
+
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func Classify(resp *http.Response, err error) Outcome {
+	if err != nil {
+		return classifyError(err)
+	}
+	if resp == nil {
+		return Success
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusInternalServerError,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return Transient
+	case resp.StatusCode >= 400:
+		return Permanent
+	}
+
+	return Success
+}
+
+func classifyError(err error) Outcome {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Transient
+	}
+
+	// NXDOMAIN is retried too: flaky resolvers and lapsed domains return it
+	// for names that come back, and the resolver's negative cache keeps the
+	// retries from reaching the network.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return Transient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Transient
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Transient
+	}
+
+	return Permanent
+}
+
+func RetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+func (self Policy) ShouldRetry(attempt int) bool {
+	return attempt < self.MaxAttempts
+}
+
+// Delay returns how long to wait before the given attempt (1 for the first
+// retry). The exponential backoff is capped at MaxDelay and jittered between
+// half and all of its value. A server-provided Retry-After raises the delay
+// and is honoured as given; when it asks for longer than MaxDelay, Delay
+// returns false and the request should be given up instead.
+func (self Policy) Delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if self.MaxDelay > 0 && retryAfter > self.MaxDelay {
+		return 0, false
+	}
+
+	d := self.BaseDelay
+	for i := 1; i < attempt && d < self.MaxDelay; i++ {
+		d *= 2
+	}
+	if self.MaxDelay > 0 {
+		d = min(d, self.MaxDelay)
+	}
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+
+	return max(d, retryAfter), true
+}
@@ -0,0 +1,149 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   Outcome
+	}{
+		{"ok", http.StatusOK, nil, Success},
+		{"redirect", http.StatusFound, nil, Success},
+		{"not found", http.StatusNotFound, nil, Permanent},
+		{"forbidden", http.StatusForbidden, nil, Permanent},
+		{"too many requests", http.StatusTooManyRequests, nil, Transient},
+		{"unavailable", http.StatusServiceUnavailable, nil, Transient},
+		{"bad gateway", http.StatusBadGateway, nil, Transient},
+		{"dns", 0, fmt.Errorf("get: %w", &net.DNSError{Err: "server misbehaving", Name: "x.example", IsTemporary: true}), Transient},
+		{"nxdomain", 0, fmt.Errorf("get: %w", &net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}), Transient},
+		{"deadline", 0, fmt.Errorf("get: %w", context.DeadlineExceeded), Transient},
+		{"refused", 0, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, Transient},
+		{"other", 0, errors.New("x509: certificate signed by unknown authority"), Permanent},
+	}
+	for _, tt := range tests {
+		var resp *http.Response
+		if tt.err == nil {
+			resp = &http.Response{StatusCode: tt.status}
+		}
+		if got := Classify(resp, tt.err); got != tt.want {
+			t.Errorf("%s: Classify = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Classify(nil, nil); got != Success {
+		t.Errorf("Classify(nil, nil) = %v; want success", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 01 Jan 2025 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.value != "" {
+			h.Set("Retry-After", tt.value)
+		}
+		got, ok := RetryAfter(h, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("RetryAfter(%q) = (%v, %v); want (%v, %v)", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, upper := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 6: 10 * time.Second} {
+		for range 50 {
+			d, ok := p.Delay(attempt, 0)
+			if !ok || d < upper/2 || d > upper {
+				t.Fatalf("Delay(%d) = %v; want within [%v, %v]", attempt, d, upper/2, upper)
+			}
+		}
+	}
+
+	if d, ok := p.Delay(1, 5*time.Second); !ok || d != 5*time.Second {
+		t.Errorf("Delay with Retry-After 5s = (%v, %v); want 5s", d, ok)
+	}
+	if d, ok := p.Delay(6, 10*time.Second); !ok || d != 10*time.Second {
+		t.Errorf("Delay with Retry-After 10s = (%v, %v); want 10s", d, ok)
+	}
+	if d, ok := p.Delay(1, time.Hour); ok {
+		t.Errorf("Delay with Retry-After 1h = %v; want to give up past MaxDelay", d)
+	}
+
+	if !p.ShouldRetry(3) || p.ShouldRetry(4) {
+		t.Error("ShouldRetry should allow attempts below MaxAttempts only")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(3, time.Minute)
+
+	for i := range 2 {
+		if b.Failure("a.example", now) {
+			t.Fatalf("Failure %d opened the circuit early", i+1)
+		}
+	}
+	if _, ok := b.Allow("a.example", now); !ok {
+		t.Fatal("Allow below threshold = false; want true")
+	}
+	if !b.Failure("a.example", now) {
+		t.Fatal("Failure at threshold = false; want circuit open")
+	}
+
+	until, ok := b.Allow("a.example", now.Add(time.Second))
+	if ok || !until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Allow while open = (%v, %v); want (%v, false)", until, ok, now.Add(time.Minute))
+	}
+	if _, ok := b.Allow("b.example", now); !ok {
+		t.Error("other hosts should not be affected")
+	}
+
+	later := now.Add(2 * time.Minute)
+	if _, ok := b.Allow("a.example", later); !ok {
+		t.Fatal("Allow after cooldown = false; want a probe")
+	}
+	if until, ok := b.Allow("a.example", later); ok || !until.Equal(later.Add(time.Minute)) {
+		t.Fatalf("second Allow while probing = (%v, %v); want (%v, false)", until, ok, later.Add(time.Minute))
+	}
+
+	b.Failure("a.example", later)
+	if _, ok := b.Allow("a.example", later.Add(time.Second)); ok {
+		t.Fatal("Allow after failed probe = true; want circuit open again")
+	}
+
+	restored := NewBreaker(3, time.Minute)
+	restored.Restore(b.Snapshot())
+	if _, ok := restored.Allow("a.example", later.Add(time.Second)); ok {
+		t.Fatal("restored Allow = true; want the open circuit to survive a restore")
+	}
+
+	b.Success("a.example")
+	if _, ok := b.Allow("a.example", later.Add(time.Second)); !ok {
+		t.Error("Allow after Success = false; want circuit closed")
+	}
+}
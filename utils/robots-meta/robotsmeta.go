@@ -0,0 +1,186 @@
+package robotsmeta
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type Directives struct {
+	NoIndex          bool
+	NoFollow         bool
+	NoArchive        bool
+	UnavailableAfter time.Time
+}
+
+var dateLayouts = []string{
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.RFC822,
+	time.RFC822Z,
+	time.RFC3339,
+	"2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+var knownDirectives = map[string]struct{}{
+	"all":               {},
+	"none":              {},
+	"index":             {},
+	"noindex":           {},
+	"follow":            {},
+	"nofollow":          {},
+	"archive":           {},
+	"noarchive":         {},
+	"nocache":           {},
+	"nosnippet":         {},
+	"notranslate":       {},
+	"noimageindex":      {},
+	"unavailable_after": {},
+	"max-snippet":       {},
+	"max-image-preview": {},
+	"max-video-preview": {},
+	"indexifembedded":   {},
+}
+
+func ProductToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+
+	return strings.ToLower(strings.TrimSpace(token))
+}
+
+func ParseHeader(userAgent string, values []string) Directives {
+	agent := ProductToken(userAgent)
+	res := Directives{}
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if head, rest, ok := strings.Cut(value, ":"); ok {
+			name := strings.ToLower(strings.TrimSpace(head))
+			if _, known := knownDirectives[name]; !known {
+				if name != agent && name != "*" {
+					continue
+				}
+				value = rest
+			}
+		}
+		res.Merge(ParseContent(value))
+	}
+
+	return res
+}
+
+func ParseMeta(userAgent string, root *html.Node) Directives {
+	agent := ProductToken(userAgent)
+	res := Directives{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, a := range n.Attr {
+				switch strings.ToLower(a.Key) {
+				case "name":
+					name = strings.ToLower(strings.TrimSpace(a.Val))
+				case "content":
+					content = a.Val
+				}
+			}
+			if name == "robots" || (agent != "" && name == agent) {
+				res.Merge(ParseContent(content))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	if root != nil {
+		walk(root)
+	}
+
+	return res
+}
+
+func ParseContent(content string) Directives {
+	res := Directives{}
+
+	parts := strings.Split(content, ",")
+	for i := 0; i < len(parts); i++ {
+		key, val, _ := strings.Cut(parts[i], ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		switch key {
+		case "none":
+			res.NoIndex = true
+			res.NoFollow = true
+		case "noindex":
+			res.NoIndex = true
+		case "nofollow":
+			res.NoFollow = true
+		case "noarchive", "nocache":
+			res.NoArchive = true
+		case "unavailable_after":
+			if t, ok := parseDate(val); ok {
+				res.mergeUnavailableAfter(t)
+			} else if i+1 < len(parts) {
+				if t, ok := parseDate(val + "," + parts[i+1]); ok {
+					res.mergeUnavailableAfter(t)
+					i++
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+func (self *Directives) Merge(other Directives) {
+	self.NoIndex = self.NoIndex || other.NoIndex
+	self.NoFollow = self.NoFollow || other.NoFollow
+	self.NoArchive = self.NoArchive || other.NoArchive
+	if !other.UnavailableAfter.IsZero() {
+		self.mergeUnavailableAfter(other.UnavailableAfter)
+	}
+}
+
+func (self Directives) Expired(now time.Time) bool {
+	return !self.UnavailableAfter.IsZero() && now.After(self.UnavailableAfter)
+}
+
+func (self Directives) String() string {
+	parts := []string{}
+	if self.NoIndex {
+		parts = append(parts, "noindex")
+	}
+	if self.NoFollow {
+		parts = append(parts, "nofollow")
+	}
+	if self.NoArchive {
+		parts = append(parts, "noarchive")
+	}
+	if !self.UnavailableAfter.IsZero() {
+		parts = append(parts, "unavailable_after: "+self.UnavailableAfter.UTC().Format(time.RFC3339))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (self *Directives) mergeUnavailableAfter(t time.Time) {
+	if self.UnavailableAfter.IsZero() || t.Before(self.UnavailableAfter) {
+		self.UnavailableAfter = t
+	}
+}
+
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,101 @@
+package robotsmeta
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const userAgent = "go-search-bot/0.0.1"
+
+func TestProductToken(t *testing.T) {
+	if got := ProductToken("Go-Search-Bot/0.0.1 (+https://example.com)"); got != "go-search-bot" {
+		t.Errorf("ProductToken = %q; want %q", got, "go-search-bot")
+	}
+}
+
+func TestParseContent(t *testing.T) {
+	d := ParseContent("NoIndex, nofollow")
+	if !d.NoIndex || !d.NoFollow || d.NoArchive {
+		t.Errorf("ParseContent = %+v; want noindex and nofollow only", d)
+	}
+
+	d = ParseContent("none")
+	if !d.NoIndex || !d.NoFollow {
+		t.Errorf("ParseContent(\"none\") = %+v; want noindex and nofollow", d)
+	}
+
+	d = ParseContent("noarchive, unavailable_after: Wednesday, 03-Nov-27 15:00:00 GMT")
+	if !d.NoArchive {
+		t.Error("expected noarchive to be set")
+	}
+	want := time.Date(2027, time.November, 3, 15, 0, 0, 0, time.UTC)
+	if !d.UnavailableAfter.Equal(want) {
+		t.Errorf("UnavailableAfter = %v; want %v", d.UnavailableAfter, want)
+	}
+}
+
+func TestParseHeader_AgentSpecific(t *testing.T) {
+	d := ParseHeader(userAgent, []string{
+		"googlebot: noindex",
+		"go-search-bot: nofollow",
+		"noarchive",
+	})
+	if d.NoIndex {
+		t.Error("directive for another agent should not apply")
+	}
+	if !d.NoFollow {
+		t.Error("directive for our agent should apply")
+	}
+	if !d.NoArchive {
+		t.Error("generic directive should apply")
+	}
+}
+
+func TestParseHeader_UnavailableAfter(t *testing.T) {
+	d := ParseHeader(userAgent, []string{"unavailable_after: 2020-01-02"})
+	if !d.Expired(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected document to be expired")
+	}
+	if d.Expired(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected document not to be expired yet")
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	const doc = `<html><head>
+<meta name="robots" content="noarchive">
+<meta name="GO-SEARCH-BOT" content="noindex">
+<meta name="otherbot" content="nofollow">
+</head><body></body></html>`
+
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("html.Parse error = %v", err)
+	}
+
+	d := ParseMeta(userAgent, root)
+	if !d.NoArchive || !d.NoIndex || d.NoFollow {
+		t.Errorf("ParseMeta = %+v; want noarchive and noindex only", d)
+	}
+}
+
+func TestDirectives_MergeAndString(t *testing.T) {
+	early := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d := Directives{NoFollow: true, UnavailableAfter: late}
+	d.Merge(Directives{NoIndex: true, UnavailableAfter: early})
+
+	if !d.NoIndex || !d.NoFollow {
+		t.Errorf("Merge = %+v; want noindex and nofollow", d)
+	}
+	if !d.UnavailableAfter.Equal(early) {
+		t.Errorf("UnavailableAfter = %v; want earliest %v", d.UnavailableAfter, early)
+	}
+	if got, want := d.String(), "noindex, nofollow, unavailable_after: 2020-01-01T00:00:00Z"; got != want {
+		t.Errorf("String = %q; want %q", got, want)
+	}
+}
@@ -2,7 +2,6 @@ package robotstxt
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -29,6 +28,16 @@ type Robotstxt struct {
 	Sitemaps []string
 }
 
+// StatusError is returned for a robots.txt answered with a status other than
+// 200, which callers need to tell an absent file from an unavailable one.
+type StatusError struct {
+	StatusCode int
+}
+
+func (self *StatusError) Error() string {
+	return fmt.Sprintf("robots.txt not found or inaccessible: status %d", self.StatusCode)
+}
+
 func newRobotstxt() *Robotstxt {
 	return &Robotstxt{
 		Groups:   make(map[agent_t]directives_t),
@@ -53,23 +62,24 @@ func (self *Parser) FetchAndParse(rawURL string) (*Robotstxt, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("robots.txt not found or inaccessible")
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
 	robots := newRobotstxt()
-	var current agent_t
+
+	// Consecutive user-agent lines share the group that follows them; the
+	// first rule after them closes the list. Blank lines carry no meaning.
+	var current []agent_t
+	inRules := false
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		line = strings.TrimSpace(line)
-
 		if idx := strings.Index(line, "#"); idx != -1 {
 			line = line[:idx]
 		}
 		line = strings.TrimSpace(line)
 		if line == "" {
-			current = ""
 			continue
 		}
 
@@ -82,21 +92,35 @@ func (self *Parser) FetchAndParse(rawURL string) (*Robotstxt, error) {
 
 		switch key {
 		case "user-agent":
-			current = agent_t(val)
-
-			if _, ok := robots.Groups[current]; !ok {
-				robots.Groups[current] = make(directives_t)
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			agent := agent_t(strings.ToLower(val))
+			current = append(current, agent)
+			if _, ok := robots.Groups[agent]; !ok {
+				robots.Groups[agent] = make(directives_t)
 			}
 
 		case "crawl-delay":
+			inRules = true
 			d, _ := strconv.Atoi(val)
-			robots.Groups[current]["crawl-delay"] = uint64(time.Duration(d) * time.Second)
+			for _, agent := range current {
+				robots.Groups[agent]["crawl-delay"] = uint64(time.Duration(d) * time.Second)
+			}
 
-		case "allow", "disallow", "host":
-			if current == "" {
-				continue
+		case "allow", "disallow":
+			inRules = true
+			for _, agent := range current {
+				rules, _ := robots.Groups[agent][key].([]string)
+				robots.Groups[agent][key] = append(rules, val)
+			}
+
+		case "host":
+			inRules = true
+			for _, agent := range current {
+				robots.Groups[agent][key] = val
 			}
-			robots.Groups[current][key] = val
 
 		case "sitemap":
 			robots.Sitemaps = append(robots.Sitemaps, val)
@@ -110,18 +134,94 @@ func (self *Parser) FetchAndParse(rawURL string) (*Robotstxt, error) {
 	return robots, nil
 }
 
-func (self *Robotstxt) GetRule(agent string, key string) (any, bool) {
-	a := agent_t(agent)
-
-	_, ok := self.Groups[a]
-	if !ok {
-		a = agent_t("*")
+// group returns the directives for agent, matched case-insensitively, or the
+// "*" group when no group names it.
+func (self *Robotstxt) group(agent string) directives_t {
+	if d, ok := self.Groups[agent_t(strings.ToLower(agent))]; ok {
+		return d
+	}
+	for name, d := range self.Groups {
+		if strings.EqualFold(string(name), agent) {
+			return d
+		}
 	}
 
-	v, ok := self.Groups[a][key]
+	return self.Groups["*"]
+}
+
+// GetRule returns the value of key in the group for agent. Allow and Disallow
+// hold every line of the group, as a []string.
+func (self *Robotstxt) GetRule(agent string, key string) (any, bool) {
+	v, ok := self.group(agent)[key]
 	if !ok {
 		return nil, false
 	}
 
 	return v, true
 }
+
+// Allowed applies RFC 9309: the longest Allow or Disallow pattern matching
+// path decides, Allow winning a tie, and a path no rule matches is allowed.
+// path is the percent-encoded path and query of the URL.
+func (self *Robotstxt) Allowed(agent string, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	d := self.group(agent)
+
+	allowed, longest := true, -1
+	for _, rule := range rules(d["disallow"]) {
+		if rule != "" && len(rule) > longest && match(rule, path) {
+			allowed, longest = false, len(rule)
+		}
+	}
+	for _, rule := range rules(d["allow"]) {
+		if rule != "" && len(rule) >= longest && match(rule, path) {
+			allowed, longest = true, len(rule)
+		}
+	}
+
+	return allowed
+}
+
+// rules reads an Allow or Disallow value, which checkpoints written before
+// groups kept every line may still hold as a single string.
+func rules(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+
+	return nil
+}
+
+// match reports whether pattern matches the start of path, where * stands for
+// any run of characters and a trailing $ anchors the pattern at the end.
+func match(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+
+	return strings.Contains(rest, last)
+}
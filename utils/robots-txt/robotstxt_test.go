@@ -1,6 +1,7 @@
 package robotstxt
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -41,8 +42,8 @@ Allow: /
 	if !ok {
 		t.Fatal("expected disallow rule for Googlebot, got none")
 	}
-	if s, _ := v.(string); s != "/private" {
-		t.Errorf("Googlebot disallow = %q; want %q", s, "/private")
+	if s, _ := v.([]string); len(s) != 1 || s[0] != "/private" {
+		t.Errorf("Googlebot disallow = %q; want [%q]", s, "/private")
 	}
 
 	v, ok = rtxt.GetRule("Googlebot", "crawl-delay")
@@ -57,8 +58,8 @@ Allow: /
 	if !ok {
 		t.Fatal("expected allow rule for *, got none")
 	}
-	if s, _ := v.(string); s != "/" {
-		t.Errorf("Wildcard allow = %q; want %q", s, "/")
+	if s, _ := v.([]string); len(s) != 1 || s[0] != "/" {
+		t.Errorf("Wildcard allow = %q; want [%q]", s, "/")
 	}
 
 	if len(rtxt.Sitemaps) != 1 || rtxt.Sitemaps[0] != "https://example.com/sitemap.xml" {
@@ -78,9 +79,14 @@ func TestFetchAndParse_HTTPError(t *testing.T) {
 	defer server.Close()
 
 	parser := &Parser{Client: server.Client()}
-	if _, err := parser.FetchAndParse(server.URL); err == nil {
+	_, err := parser.FetchAndParse(server.URL)
+	if err == nil {
 		t.Fatal("expected error for 404 response, got nil")
 	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("FetchAndParse error = %v; want a StatusError with 404", err)
+	}
 }
 
 func TestGetRule_Fallback(t *testing.T) {
@@ -108,3 +114,109 @@ func TestGetRule_MissingKey(t *testing.T) {
 		t.Error("expected no disallow rule for Googlebot, but got one")
 	}
 }
+
+func TestAllowed(t *testing.T) {
+	rtxt := &Robotstxt{
+		Groups: map[agent_t]directives_t{
+			"*":         {"disallow": "/private"},
+			"Googlebot": {"disallow": "/", "allow": "/public"},
+		},
+	}
+
+	cases := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"AnyBot", "/", true},
+		{"AnyBot", "/private/page", false},
+		{"AnyBot", "", true},
+		{"Googlebot", "/public/page", true},
+		{"Googlebot", "/other", false},
+	}
+	for _, c := range cases {
+		if got := rtxt.Allowed(c.agent, c.path); got != c.want {
+			t.Errorf("Allowed(%q, %q) = %v; want %v", c.agent, c.path, got, c.want)
+		}
+	}
+}
+
+func TestFetchAndParse_SeveralRulesPerGroup(t *testing.T) {
+	const robotsContent = `
+User-agent: GoodBot
+User-agent: OtherBot
+Disallow: /private
+Disallow: /tmp/
+
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: *
+Disallow: /
+Allow: /open
+`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, robotsContent)
+	}))
+	defer server.Close()
+
+	parser := &Parser{Client: server.Client()}
+	rtxt, err := parser.FetchAndParse(server.URL)
+	if err != nil {
+		t.Fatalf("FetchAndParse error = %v; want nil", err)
+	}
+
+	cases := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"goodbot", "/private/page", false},
+		{"GOODBOT", "/tmp/file", false},
+		{"GoodBot", "/private/public/page", true},
+		{"GoodBot", "/docs/paper.pdf", false},
+		{"GoodBot", "/docs/paper.pdf?download=1", true},
+		{"OtherBot", "/tmp/file", false},
+		{"OtherBot", "/index.html", true},
+		{"AnyBot", "/index.html", false},
+		{"AnyBot", "/open/page", true},
+	}
+	for _, c := range cases {
+		if got := rtxt.Allowed(c.agent, c.path); got != c.want {
+			t.Errorf("Allowed(%q, %q) = %v; want %v", c.agent, c.path, got, c.want)
+		}
+	}
+
+	if v, _ := rtxt.GetRule("otherbot", "crawl-delay"); v != uint64(2*time.Second) {
+		t.Errorf("OtherBot crawl-delay = %v; want the shared group's 2s", v)
+	}
+}
+
+func TestAllowed_LongestMatch(t *testing.T) {
+	rtxt := &Robotstxt{
+		Groups: map[agent_t]directives_t{
+			"*": {
+				"disallow": []string{"/shop", "/shop/cart/", "/*/print", ""},
+				"allow":    []string{"/shop/", "/shop/cart/"},
+			},
+		},
+	}
+
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"/shop", false},
+		{"/shop/item", true},
+		{"/shop/cart/checkout", true},
+		{"/news/print", false},
+		{"/news", true},
+	}
+	for _, c := range cases {
+		if got := rtxt.Allowed("AnyBot", c.path); got != c.want {
+			t.Errorf("Allowed(%q) = %v; want %v", c.path, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package scope
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/guilherme13c/go-search/utils/surt"
+)
+
+type Mode string
+
+const (
+	ModeAny    Mode = "any"
+	ModeHost   Mode = "host"
+	ModeDomain Mode = "domain"
+	ModePrefix Mode = "prefix"
+)
+
+const surtRulePrefix = "surt:"
+
+type Reason string
+
+const (
+	ReasonInvalid   Reason = "invalid-url"
+	ReasonScheme    Reason = "scheme"
+	ReasonExtension Reason = "extension"
+	ReasonExcluded  Reason = "excluded"
+	ReasonMaxHops   Reason = "max-hops"
+	ReasonSeed      Reason = "out-of-seed-scope"
+	ReasonUnknown   Reason = "unknown-seed"
+)
+
+type Rejection struct {
+	URL    string
+	Reason Reason
+	Detail string
+}
+
+func (self *Rejection) Error() string {
+	if self.Detail == "" {
+		return fmt.Sprintf("%s: %s", self.Reason, self.URL)
+	}
+	return fmt.Sprintf("%s (%s): %s", self.Reason, self.Detail, self.URL)
+}
+
+type Seed struct {
+	URL     string
+	Mode    Mode
+	MaxHops int
+}
+
+type Options struct {
+	Mode              Mode
+	MaxHops           int
+	Include           []string
+	Exclude           []string
+	BlockedExtensions []string
+}
+
+type Scope interface {
+	AddSeed(Seed) error
+	Check(rawURL string, seed string, hops int) error
+}
+
+type rule struct {
+	source string
+	prefix string
+	regex  *regexp.Regexp
+}
+
+type seedScope struct {
+	mode    Mode
+	maxHops int
+	host    string
+	domain  string
+	prefix  string
+}
+
+type scope struct {
+	mu         sync.RWMutex
+	opts       Options
+	include    []rule
+	exclude    []rule
+	extensions map[string]struct{}
+	seeds      map[string]seedScope
+}
+
+func NewScope(opts Options) (Scope, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeAny
+	}
+	if err := opts.Mode.validate(); err != nil {
+		return nil, err
+	}
+
+	include, err := parseRules(opts.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include rule error: %w", err)
+	}
+	exclude, err := parseRules(opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude rule error: %w", err)
+	}
+
+	extensions := make(map[string]struct{}, len(opts.BlockedExtensions))
+	for _, ext := range opts.BlockedExtensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			extensions["."+ext] = struct{}{}
+		}
+	}
+
+	return &scope{
+		mu:         sync.RWMutex{},
+		opts:       opts,
+		include:    include,
+		exclude:    exclude,
+		extensions: extensions,
+		seeds:      make(map[string]seedScope),
+	}, nil
+}
+
+func (self Mode) validate() error {
+	switch self {
+	case ModeAny, ModeHost, ModeDomain, ModePrefix:
+		return nil
+	}
+	return fmt.Errorf("unknown scope mode %q", string(self))
+}
+
+func parseRules(sources []string) ([]rule, error) {
+	res := make([]rule, 0, len(sources))
+	for _, src := range sources {
+		if prefix, ok := strings.CutPrefix(src, surtRulePrefix); ok {
+			res = append(res, rule{source: src, prefix: strings.ToLower(prefix)})
+			continue
+		}
+
+		re, err := regexp.Compile(src)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rule{source: src, regex: re})
+	}
+
+	return res, nil
+}
+
+func (self rule) match(rawURL string, key string) bool {
+	if self.regex != nil {
+		return self.regex.MatchString(rawURL)
+	}
+	return strings.HasPrefix(key, self.prefix)
+}
+
+func (self *scope) AddSeed(seed Seed) error {
+	if seed.Mode == "" {
+		seed.Mode = self.opts.Mode
+	}
+	if err := seed.Mode.validate(); err != nil {
+		return err
+	}
+	if seed.MaxHops == 0 {
+		seed.MaxHops = self.opts.MaxHops
+	}
+
+	u, err := url.Parse(seed.URL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid seed %q", seed.URL)
+	}
+	key, err := surt.Surt(seed.URL)
+	if err != nil {
+		return fmt.Errorf("invalid seed %q: %w", seed.URL, err)
+	}
+
+	s := seedScope{
+		mode:    seed.Mode,
+		maxHops: seed.MaxHops,
+		host:    surt.Host(u.Hostname()),
+		domain:  registrableDomain(u.Hostname()),
+		prefix:  key,
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.seeds[seed.URL] = s
+
+	return nil
+}
+
+func (self *scope) Check(rawURL string, seed string, hops int) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return &Rejection{URL: rawURL, Reason: ReasonInvalid}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &Rejection{URL: rawURL, Reason: ReasonScheme, Detail: u.Scheme}
+	}
+
+	if ext := strings.ToLower(path.Ext(u.Path)); ext != "" {
+		if _, blocked := self.extensions[ext]; blocked {
+			return &Rejection{URL: rawURL, Reason: ReasonExtension, Detail: ext}
+		}
+	}
+
+	key, err := surt.Surt(rawURL)
+	if err != nil {
+		return &Rejection{URL: rawURL, Reason: ReasonInvalid}
+	}
+
+	for _, r := range self.exclude {
+		if r.match(rawURL, key) {
+			return &Rejection{URL: rawURL, Reason: ReasonExcluded, Detail: r.source}
+		}
+	}
+
+	self.mu.RLock()
+	s, ok := self.seeds[seed]
+	self.mu.RUnlock()
+	if !ok {
+		return &Rejection{URL: rawURL, Reason: ReasonUnknown, Detail: seed}
+	}
+
+	if s.maxHops > 0 && hops > s.maxHops {
+		return &Rejection{URL: rawURL, Reason: ReasonMaxHops, Detail: fmt.Sprintf("%d > %d", hops, s.maxHops)}
+	}
+
+	for _, r := range self.include {
+		if r.match(rawURL, key) {
+			return nil
+		}
+	}
+
+	if !s.contains(u, key) {
+		return &Rejection{URL: rawURL, Reason: ReasonSeed, Detail: string(s.mode) + " " + seed}
+	}
+
+	return nil
+}
+
+func (self seedScope) contains(u *url.URL, key string) bool {
+	switch self.mode {
+	case ModeHost:
+		return surt.Host(u.Hostname()) == self.host
+	case ModeDomain:
+		return registrableDomain(u.Hostname()) == self.domain
+	case ModePrefix:
+		return strings.HasPrefix(key, self.prefix)
+	}
+
+	return true
+}
+
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
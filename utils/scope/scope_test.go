@@ -0,0 +1,108 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+)
+
+func reason(err error) Reason {
+	var r *Rejection
+	if errors.As(err, &r) {
+		return r.Reason
+	}
+	return ""
+}
+
+func TestCheck_SeedModes(t *testing.T) {
+	s, err := NewScope(Options{})
+	if err != nil {
+		t.Fatalf("NewScope error = %v", err)
+	}
+	seeds := []Seed{
+		{URL: "https://www.example.com/", Mode: ModeHost},
+		{URL: "https://news.example.co.uk/", Mode: ModeDomain},
+		{URL: "https://example.org/blog/", Mode: ModePrefix},
+		{URL: "https://example.net/"},
+	}
+	for _, seed := range seeds {
+		if err := s.AddSeed(seed); err != nil {
+			t.Fatalf("AddSeed(%+v) error = %v", seed, err)
+		}
+	}
+
+	tests := []struct {
+		url  string
+		seed string
+		want Reason
+	}{
+		{"https://example.com/a", "https://www.example.com/", ""},
+		{"http://www.example.com/b", "https://www.example.com/", ""},
+		{"https://shop.example.com/", "https://www.example.com/", ReasonSeed},
+		{"https://sport.example.co.uk/", "https://news.example.co.uk/", ""},
+		{"https://other.co.uk/", "https://news.example.co.uk/", ReasonSeed},
+		{"https://example.org/blog/post-1", "https://example.org/blog/", ""},
+		{"https://example.org/about", "https://example.org/blog/", ReasonSeed},
+		{"https://anything.example/", "https://example.net/", ""},
+		{"https://example.com/", "https://unknown.example/", ReasonUnknown},
+		{"ftp://example.com/", "https://www.example.com/", ReasonScheme},
+	}
+	for _, tt := range tests {
+		if got := reason(s.Check(tt.url, tt.seed, 1)); got != tt.want {
+			t.Errorf("Check(%q, %q) = %q; want %q", tt.url, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestCheck_Rules(t *testing.T) {
+	s, err := NewScope(Options{
+		Mode:              ModeHost,
+		MaxHops:           2,
+		Include:           []string{"surt:org,example)/"},
+		Exclude:           []string{`[?&]sessionid=`, "surt:com,example)/private"},
+		BlockedExtensions: []string{"PDF", ".zip"},
+	})
+	if err != nil {
+		t.Fatalf("NewScope error = %v", err)
+	}
+	if err := s.AddSeed(Seed{URL: "https://example.com/"}); err != nil {
+		t.Fatalf("AddSeed error = %v", err)
+	}
+	if err := s.AddSeed(Seed{URL: "https://deep.example.com/", MaxHops: 5}); err != nil {
+		t.Fatalf("AddSeed error = %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		seed string
+		hops int
+		want Reason
+	}{
+		{"https://example.com/doc.pdf", "https://example.com/", 1, ReasonExtension},
+		{"https://example.com/a.ZIP", "https://example.com/", 1, ReasonExtension},
+		{"https://example.com/a?sessionid=1", "https://example.com/", 1, ReasonExcluded},
+		{"https://www.example.com/private/x", "https://example.com/", 1, ReasonExcluded},
+		{"https://example.org/page", "https://example.com/", 1, ""},
+		{"https://example.com/far", "https://example.com/", 3, ReasonMaxHops},
+		{"https://deep.example.com/far", "https://deep.example.com/", 5, ""},
+		{"https://deep.example.com/far", "https://deep.example.com/", 6, ReasonMaxHops},
+	}
+	for _, tt := range tests {
+		if got := reason(s.Check(tt.url, tt.seed, tt.hops)); got != tt.want {
+			t.Errorf("Check(%q, hops %d) = %q; want %q", tt.url, tt.hops, got, tt.want)
+		}
+	}
+}
+
+func TestNewScope_Invalid(t *testing.T) {
+	if _, err := NewScope(Options{Mode: "galaxy"}); err == nil {
+		t.Error("NewScope with unknown mode error = nil; want error")
+	}
+	if _, err := NewScope(Options{Exclude: []string{"("}}); err == nil {
+		t.Error("NewScope with invalid regex error = nil; want error")
+	}
+
+	s, _ := NewScope(Options{})
+	if err := s.AddSeed(Seed{URL: "not a url"}); err == nil {
+		t.Error("AddSeed(invalid) error = nil; want error")
+	}
+}
@@ -3,20 +3,27 @@ package set
 import (
 	"crypto/md5"
 	"encoding/json"
+	"fmt"
+	"sync"
 )
 
 type Set[T any] interface {
 	Add(T)
 	Remove(T)
 	Contains(T) bool
+	Len() int
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary([]byte) error
 }
 
 type set[T any] struct {
+	mu   sync.RWMutex
 	data map[string]struct{}
 }
 
 func NewSet[T any]() Set[T] {
 	return &set[T]{
+		mu:   sync.RWMutex{},
 		data: map[string]struct{}{},
 	}
 }
@@ -24,23 +31,66 @@ func NewSet[T any]() Set[T] {
 func (self *set[T]) Add(element T) {
 	k := self.getKey(element)
 
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	self.data[k] = struct{}{}
 }
 
 func (self *set[T]) Remove(element T) {
 	k := self.getKey(element)
 
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
 	delete(self.data, k)
 }
 
 func (self *set[T]) Contains(element T) bool {
 	k := self.getKey(element)
 
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
 	_, ok := self.data[k]
 
 	return ok
 }
 
+func (self *set[T]) Len() int {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	return len(self.data)
+}
+
+func (self *set[T]) MarshalBinary() ([]byte, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	res := make([]byte, 0, len(self.data)*md5.Size)
+	for k := range self.data {
+		res = append(res, k...)
+	}
+
+	return res, nil
+}
+
+func (self *set[T]) UnmarshalBinary(data []byte) error {
+	if len(data)%md5.Size != 0 {
+		return fmt.Errorf("invalid set encoding: length %d is not a multiple of %d", len(data), md5.Size)
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for i := 0; i < len(data); i += md5.Size {
+		self.data[string(data[i:i+md5.Size])] = struct{}{}
+	}
+
+	return nil
+}
+
 func (self *set[T]) getKey(element T) string {
 	b, err := json.Marshal(element)
 	if err != nil {
@@ -1,6 +1,7 @@
 package set
 
 import (
+	"sync"
 	"testing"
 )
 
@@ -74,3 +75,58 @@ func TestStructSet(t *testing.T) {
 		t.Error("set should not contain Alice after removal")
 	}
 }
+
+func TestConcurrentAddContains(t *testing.T) {
+	s := NewSet[int]()
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				s.Add(i*100 + j)
+				s.Contains(j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range 1600 {
+		if !s.Contains(i) {
+			t.Fatalf("set should contain %d after concurrent adds", i)
+		}
+	}
+}
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	s := NewSet[string]()
+	for _, v := range []string{"a", "b", "c"} {
+		s.Add(v)
+	}
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary error = %v", err)
+	}
+
+	restored := NewSet[string]()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary error = %v", err)
+	}
+	if got := restored.Len(); got != 3 {
+		t.Errorf("Len after round trip = %d; want 3", got)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if !restored.Contains(v) {
+			t.Errorf("restored set should contain %q", v)
+		}
+	}
+	if restored.Contains("d") {
+		t.Error("restored set should not contain \"d\"")
+	}
+
+	if err := restored.UnmarshalBinary([]byte("short")); err == nil {
+		t.Error("UnmarshalBinary of truncated data should fail")
+	}
+}
@@ -0,0 +1,91 @@
+package surt
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var wwwPrefix = regexp.MustCompile(`^www\d*\.`)
+
+func Surt(rawURL string) (string, error) {
+	u, err := parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(Host(u.Hostname()))
+	if port := u.Port(); port != "" && !isDefaultPort(u.Scheme, port) {
+		sb.WriteString(":" + port)
+	}
+	sb.WriteString(")")
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	sb.WriteString(strings.ToLower(path))
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		sort.Strings(params)
+		sb.WriteString("?" + strings.ToLower(strings.Join(params, "&")))
+	}
+
+	return sb.String(), nil
+}
+
+func Host(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	host = wwwPrefix.ReplaceAllString(host, "")
+
+	if strings.Contains(host, ":") || isIPv4(host) {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ",")
+}
+
+func parse(rawURL string) (*url.URL, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", rawURL)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	return u, nil
+}
+
+func isDefaultPort(scheme string, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
+
+func isIPv4(host string) bool {
+	parts := strings.Split(host, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, p := range parts {
+		if p == "" || strings.Trim(p, "0123456789") != "" {
+			return false
+		}
+	}
+
+	return true
+}
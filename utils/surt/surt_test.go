@@ -0,0 +1,41 @@
+package surt
+
+import (
+	"testing"
+)
+
+func TestSurt(t *testing.T) {
+	cases := map[string]string{
+		"http://www.Example.com/Path?b=2&a=1#frag": "com,example)/path?a=1&b=2",
+		"https://example.com":                      "com,example)/",
+		"https://example.com:443/x":                "com,example)/x",
+		"http://sub.example.co.uk:8080/":           "uk,co,example,sub:8080)/",
+		"example.org/page":                         "org,example)/page",
+		"http://www2.example.com/":                 "com,example)/",
+		"http://192.168.0.1/admin":                 "192.168.0.1)/admin",
+	}
+	for in, want := range cases {
+		got, err := Surt(in)
+		if err != nil {
+			t.Errorf("Surt(%q) error = %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Surt(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestSurt_Invalid(t *testing.T) {
+	for _, in := range []string{"http://", "://%%invalid"} {
+		if _, err := Surt(in); err == nil {
+			t.Errorf("Surt(%q) error = nil; want error", in)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	if got := Host("WWW.Example.COM."); got != "com,example" {
+		t.Errorf("Host = %q; want %q", got, "com,example")
+	}
+}
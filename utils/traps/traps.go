@@ -0,0 +1,275 @@
+package traps
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Rule string
+
+const (
+	RulePathDepth       Rule = "path-depth"
+	RulePathLength      Rule = "path-length"
+	RuleRepeatedSegment Rule = "repeated-segment"
+	RuleTemplate        Rule = "template-cap"
+)
+
+var Rules = []Rule{RulePathDepth, RulePathLength, RuleRepeatedSegment, RuleTemplate}
+
+// DefaultSessionParams only holds names that carry nothing but a session ID.
+// Short names such as sid also mean site or story IDs on many sites.
+var DefaultSessionParams = []string{
+	"jsessionid", "phpsessid", "aspsessionid", "sessionid", "session_id", "sessid", "cfid", "cftoken", "zenid", "osCsid",
+}
+
+type Trap struct {
+	URL    string
+	Rule   Rule
+	Detail string
+}
+
+func (self *Trap) Error() string {
+	return fmt.Sprintf("trap %s (%s): %s", self.Rule, self.Detail, self.URL)
+}
+
+// A zero limit disables its rule.
+type Options struct {
+	MaxPathDepth   int
+	MaxPathLength  int
+	MaxRepeats     int
+	MaxPerTemplate int
+	SessionParams  []string
+}
+
+type Detector interface {
+	Normalize(rawURL string) string
+	Check(rawURL string) error
+	Counts() map[Rule]int64
+	Normalized() int64
+	Snapshot() State
+	Restore(state State)
+}
+
+// State is what a Detector has counted so far, kept across checkpoints so the
+// template cap is not reset by a restart.
+type State struct {
+	Templates  map[string]int
+	Counts     map[Rule]int64
+	Normalized int64
+}
+
+type detector struct {
+	opts          Options
+	sessionParams map[string]struct{}
+	mu            sync.Mutex
+	templates     map[string]int
+	counts        map[Rule]*atomic.Int64
+	normalized    atomic.Int64
+}
+
+var (
+	digitsPattern = regexp.MustCompile(`[0-9]+`)
+	idPattern     = regexp.MustCompile(`^(?i:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*|[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*)$`)
+)
+
+func NewDetector(opts Options) Detector {
+	sessionParams := make(map[string]struct{}, len(opts.SessionParams))
+	for _, name := range opts.SessionParams {
+		sessionParams[strings.ToLower(name)] = struct{}{}
+	}
+	counts := make(map[Rule]*atomic.Int64, len(Rules))
+	for _, rule := range Rules {
+		counts[rule] = &atomic.Int64{}
+	}
+
+	return &detector{
+		opts:          opts,
+		sessionParams: sessionParams,
+		mu:            sync.Mutex{},
+		templates:     make(map[string]int),
+		counts:        counts,
+	}
+}
+
+func (self *detector) isSessionParam(name string) bool {
+	_, ok := self.sessionParams[strings.ToLower(name)]
+	return ok
+}
+
+// Normalize removes session-ID query parameters and ;jsessionid= style path
+// parameters, which otherwise give every visit a fresh copy of each URL. It
+// rejects nothing, so a URL it changes is counted apart from the rules.
+func (self *detector) Normalize(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(self.sessionParams) == 0 {
+		return rawURL
+	}
+
+	stripped := false
+	if strings.Contains(u.Path, ";") {
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			name, params, ok := strings.Cut(segment, ";")
+			if !ok {
+				continue
+			}
+			kept := []string{}
+			for _, param := range strings.Split(params, ";") {
+				key, _, _ := strings.Cut(param, "=")
+				if self.isSessionParam(key) {
+					stripped = true
+					continue
+				}
+				kept = append(kept, param)
+			}
+			segments[i] = strings.Join(append([]string{name}, kept...), ";")
+		}
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+	}
+
+	if u.RawQuery != "" {
+		pairs := strings.Split(u.RawQuery, "&")
+		kept := pairs[:0]
+		for _, pair := range pairs {
+			key, _, _ := strings.Cut(pair, "=")
+			if name, err := url.QueryUnescape(key); err == nil && self.isSessionParam(name) {
+				stripped = true
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+
+	if !stripped {
+		return rawURL
+	}
+	self.normalized.Add(1)
+
+	return u.String()
+}
+
+// Check reports whether rawURL looks like part of an infinite URL space. A URL
+// that passes is counted against its host's path template, so Check should be
+// called once per newly discovered URL.
+func (self *detector) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	path := u.EscapedPath()
+	if self.opts.MaxPathLength > 0 && len(path) > self.opts.MaxPathLength {
+		return self.trap(rawURL, RulePathLength, fmt.Sprintf("%d > %d", len(path), self.opts.MaxPathLength))
+	}
+
+	segments := []string{}
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if self.opts.MaxPathDepth > 0 && len(segments) > self.opts.MaxPathDepth {
+		return self.trap(rawURL, RulePathDepth, fmt.Sprintf("%d > %d", len(segments), self.opts.MaxPathDepth))
+	}
+
+	if self.opts.MaxRepeats > 0 {
+		seen := make(map[string]int, len(segments))
+		for _, segment := range segments {
+			seen[segment]++
+			if seen[segment] > self.opts.MaxRepeats {
+				return self.trap(rawURL, RuleRepeatedSegment, fmt.Sprintf("%q x%d", segment, seen[segment]))
+			}
+		}
+	}
+
+	if self.opts.MaxPerTemplate > 0 {
+		key := strings.ToLower(u.Host) + template(segments, u.Query())
+
+		self.mu.Lock()
+		n := self.templates[key]
+		if n < self.opts.MaxPerTemplate {
+			self.templates[key] = n + 1
+		}
+		self.mu.Unlock()
+
+		if n >= self.opts.MaxPerTemplate {
+			return self.trap(rawURL, RuleTemplate, key)
+		}
+	}
+
+	return nil
+}
+
+func (self *detector) trap(rawURL string, rule Rule, detail string) error {
+	self.counts[rule].Add(1)
+	return &Trap{URL: rawURL, Rule: rule, Detail: detail}
+}
+
+func (self *detector) Counts() map[Rule]int64 {
+	res := make(map[Rule]int64, len(self.counts))
+	for rule, n := range self.counts {
+		res[rule] = n.Load()
+	}
+
+	return res
+}
+
+func (self *detector) Snapshot() State {
+	self.mu.Lock()
+	templates := maps.Clone(self.templates)
+	self.mu.Unlock()
+
+	return State{Templates: templates, Counts: self.Counts(), Normalized: self.normalized.Load()}
+}
+
+func (self *detector) Restore(state State) {
+	self.mu.Lock()
+	for key, n := range state.Templates {
+		self.templates[key] = max(self.templates[key], n)
+	}
+	self.mu.Unlock()
+
+	for rule, n := range state.Counts {
+		if count, ok := self.counts[rule]; ok {
+			count.Store(n)
+		}
+	}
+	self.normalized.Store(state.Normalized)
+}
+
+// Normalized returns how many URLs Normalize has stripped of session
+// parameters.
+func (self *detector) Normalized() int64 {
+	return self.normalized.Load()
+}
+
+// template reduces a path to its shape so that the pages of a calendar or a
+// paginated listing share one key: IDs become {id}, digit runs become {n} and
+// only the names of query parameters are kept.
+func template(segments []string, query url.Values) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		b.WriteByte('/')
+		if len(segment) >= 8 && idPattern.MatchString(segment) {
+			b.WriteString("{id}")
+			continue
+		}
+		b.WriteString(digitsPattern.ReplaceAllString(segment, "{n}"))
+	}
+
+	if len(query) > 0 {
+		keys := slices.Sorted(maps.Keys(query))
+		b.WriteByte('?')
+		b.WriteString(strings.Join(keys, "&"))
+	}
+
+	return b.String()
+}
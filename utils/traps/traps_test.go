@@ -0,0 +1,110 @@
+package traps
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func rule(err error) Rule {
+	var trap *Trap
+	if errors.As(err, &trap) {
+		return trap.Rule
+	}
+	return ""
+}
+
+func TestCheck(t *testing.T) {
+	d := NewDetector(Options{MaxPathDepth: 6, MaxPathLength: 80, MaxRepeats: 2})
+
+	tests := []struct {
+		url  string
+		want Rule
+	}{
+		{"https://example.com/a/b/c", ""},
+		{"https://example.com/1/2/3/4/5/6/7", RulePathDepth},
+		{"https://example.com/" + fmt.Sprintf("%090d", 0), RulePathLength},
+		{"https://example.com/a/a/a", RuleRepeatedSegment},
+		{"https://example.com/x/y/x/y/x/y", RuleRepeatedSegment},
+		{"https://example.com/a/b/a/b", ""},
+	}
+	for _, tt := range tests {
+		if got := rule(d.Check(tt.url)); got != tt.want {
+			t.Errorf("Check(%q) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+
+	counts := d.Counts()
+	if counts[RulePathDepth] != 1 || counts[RulePathLength] != 1 || counts[RuleRepeatedSegment] != 2 {
+		t.Errorf("Counts = %v; want one depth, one length and two repeat hits", counts)
+	}
+}
+
+func TestCheck_TemplateCap(t *testing.T) {
+	d := NewDetector(Options{MaxPerTemplate: 3})
+
+	for day := 1; day <= 3; day++ {
+		if err := d.Check(fmt.Sprintf("https://cal.example/events/2025/01/%02d?view=day", day)); err != nil {
+			t.Fatalf("Check(day %d) error = %v; want allowed below the cap", day, err)
+		}
+	}
+	if got := rule(d.Check("https://cal.example/events/2025/02/01?view=month")); got != RuleTemplate {
+		t.Errorf("fourth calendar page = %q; want %q", got, RuleTemplate)
+	}
+
+	if err := d.Check("https://other.example/events/2025/02/01?view=month"); err != nil {
+		t.Errorf("same template on another host error = %v; want allowed", err)
+	}
+	if err := d.Check("https://cal.example/events/2025/02/01"); err != nil {
+		t.Errorf("different query keys error = %v; want a separate template", err)
+	}
+	for _, id := range []string{"3f2504e0-4f89-11d3-9a0c-0305e82c3301", "5d41402abc4b2a76", "b1946ac92492d2347c62"} {
+		d.Check("https://cal.example/item/" + id)
+	}
+	if got := rule(d.Check("https://cal.example/item/0cc175b9c0f1b6a831c399e269772661")); got != RuleTemplate {
+		t.Errorf("fourth id page = %q; want ids to share a template", got)
+	}
+
+	if d.Counts()[RuleTemplate] != 2 {
+		t.Errorf("Counts[%s] = %d; want 2", RuleTemplate, d.Counts()[RuleTemplate])
+	}
+
+	restored := NewDetector(Options{MaxPerTemplate: 3})
+	restored.Restore(d.Snapshot())
+	if got := rule(restored.Check("https://cal.example/events/2025/03/01?view=day")); got != RuleTemplate {
+		t.Errorf("restored calendar page = %q; want the cap to survive a restore", got)
+	}
+	if restored.Counts()[RuleTemplate] != 3 {
+		t.Errorf("restored Counts[%s] = %d; want 3", RuleTemplate, restored.Counts()[RuleTemplate])
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	d := NewDetector(Options{SessionParams: DefaultSessionParams})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/page?id=7&PHPSESSID=abc123", "https://example.com/page?id=7"},
+		{"https://example.com/page;jsessionid=ABCDEF?x=1", "https://example.com/page?x=1"},
+		{"https://example.com/shop/cart;jsessionid=1;lang=en", "https://example.com/shop/cart;lang=en"},
+		{"https://example.com/page?oscsid=1&b=2&sid=3", "https://example.com/page?b=2&sid=3"},
+		{"https://example.com/page?session=1", "https://example.com/page?session=1"},
+		{"https://example.com/plain", "https://example.com/plain"},
+	}
+	for _, tt := range tests {
+		if got := d.Normalize(tt.url); got != tt.want {
+			t.Errorf("Normalize(%q) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+
+	for rule, n := range d.Counts() {
+		if n != 0 {
+			t.Errorf("Counts[%s] = %d; want no rule counted for stripped parameters", rule, n)
+		}
+	}
+	if n := d.Normalized(); n != 4 {
+		t.Errorf("Normalized = %d; want 4", n)
+	}
+}
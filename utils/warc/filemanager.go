@@ -0,0 +1,394 @@
+package warc
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxFileSize = 1 << 30
+
+type Location struct {
+	Filename string
+	Offset   int64
+	Length   int64
+}
+
+type FileManagerOptions struct {
+	Dir      string
+	Prefix   string
+	Hostname string
+	MaxSize  int64
+	Compress bool
+	Serial   int
+	Info     Header
+}
+
+type FileManager interface {
+	WriteRecords(...*Record) ([]Location, error)
+	Serial() int
+	Close() error
+}
+
+type fileManager struct {
+	mu sync.Mutex
+
+	opts FileManagerOptions
+
+	current *warcFile
+	serial  int
+}
+
+// warcFile is a file being written. Writers reserve it before encoding their
+// records and append them after, so a file that fills up in between stays
+// open until the last of them is done.
+type warcFile struct {
+	file       *os.File
+	filename   string
+	size       int64
+	warcinfoID string
+	pending    int
+	full       bool
+	broken     bool
+}
+
+func NewFileManager(opts FileManagerOptions) (FileManager, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "warc"
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxFileSize
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create dir error: %w", err)
+	}
+	if err := recoverOpenFiles(opts.Dir, opts.Prefix); err != nil {
+		return nil, err
+	}
+
+	return &fileManager{
+		mu:     sync.Mutex{},
+		opts:   opts,
+		serial: opts.Serial,
+	}, nil
+}
+
+// WriteRecords appends the records to the current file, one after the other.
+// They are encoded, and compressed, before the lock is taken for the append,
+// and either all of them are written or none is.
+func (self *fileManager) WriteRecords(records ...*Record) ([]Location, error) {
+	f, err := self.reserve()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]Spool, 0, len(records))
+	defer func() {
+		for _, member := range members {
+			member.Close()
+		}
+	}()
+	var errEncode error
+	for _, rec := range records {
+		if !rec.Header.Has(FieldWarcinfoID) && rec.Type() != TypeWarcinfo {
+			rec.Header.Set(FieldWarcinfoID, f.warcinfoID)
+		}
+		member := NewSpool()
+		members = append(members, member)
+		if errEncode = self.encode(member, rec); errEncode != nil {
+			break
+		}
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var locations []Location
+	if errEncode == nil {
+		locations, errEncode = self.append(f, members)
+	}
+	f.pending--
+	if f.full && f.pending == 0 {
+		if err := self.closeFile(f); err != nil {
+			return locations, errors.Join(errEncode, err)
+		}
+	}
+
+	return locations, errEncode
+}
+
+func (self *fileManager) Serial() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.serial
+}
+
+// Close closes the current file; one still reserved by a writer is closed
+// when that writer is done.
+func (self *fileManager) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.current == nil {
+		return nil
+	}
+
+	return self.retire(self.current)
+}
+
+// reserve returns the file the next records go to, opening a new one when the
+// current one is full.
+func (self *fileManager) reserve() (*warcFile, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.current != nil && self.current.size >= self.opts.MaxSize {
+		if err := self.retire(self.current); err != nil {
+			return nil, err
+		}
+	}
+	if self.current == nil {
+		if err := self.openFile(); err != nil {
+			return nil, err
+		}
+	}
+	self.current.pending++
+
+	return self.current, nil
+}
+
+// retire takes f out of use and closes it unless a writer still has it
+// reserved. mu must be held.
+func (self *fileManager) retire(f *warcFile) error {
+	f.full = true
+	if self.current == f {
+		self.current = nil
+	}
+	if f.pending > 0 {
+		return nil
+	}
+
+	return self.closeFile(f)
+}
+
+func (self *fileManager) openFile() error {
+	ext := ".warc"
+	if self.opts.Compress {
+		ext += ".gz"
+	}
+
+	f := &warcFile{}
+	for {
+		name := fmt.Sprintf("%s-%s-%05d-%s%s",
+			self.opts.Prefix,
+			time.Now().UTC().Format("20060102150405"),
+			self.serial,
+			sanitizeFilenamePart(self.opts.Hostname),
+			ext,
+		)
+		self.serial++
+
+		file, err := os.OpenFile(filepath.Join(self.opts.Dir, name+".open"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("create file error: %w", err)
+		}
+		if _, err := os.Stat(filepath.Join(self.opts.Dir, name)); err == nil {
+			file.Close()
+			os.Remove(file.Name())
+			continue
+		}
+
+		f.file = file
+		f.filename = name
+		break
+	}
+
+	info := NewRecord(TypeWarcinfo)
+	info.Header.Set(FieldFilename, f.filename)
+	info.Header.Set(FieldContentType, "application/warc-fields")
+	info.Payload = strings.NewReader(self.infoFields())
+	f.warcinfoID = info.ID()
+
+	member := NewSpool()
+	defer member.Close()
+	err := self.encode(member, info)
+	if err == nil {
+		_, err = self.append(f, []Spool{member})
+	}
+	if err != nil {
+		f.file.Close()
+		os.Remove(f.file.Name())
+		return err
+	}
+	self.current = f
+
+	return nil
+}
+
+// closeFile syncs and closes f and drops its .open suffix. A broken file
+// keeps the suffix, so that the next start cuts it back to its last complete
+// record.
+func (self *fileManager) closeFile(f *warcFile) error {
+	path := f.file.Name()
+	errSync := f.file.Sync()
+	errClose := f.file.Close()
+
+	if err := errors.Join(errSync, errClose); err != nil {
+		return fmt.Errorf("close file error: %w", err)
+	}
+	if f.broken {
+		return nil
+	}
+	if err := os.Rename(path, strings.TrimSuffix(path, ".open")); err != nil {
+		return fmt.Errorf("rename file error: %w", err)
+	}
+
+	return nil
+}
+
+// encode writes rec to w as it will appear in the file, as a gzip member of
+// its own when compressing.
+func (self *fileManager) encode(w io.Writer, rec *Record) error {
+	if !self.opts.Compress {
+		_, err := NewWriter(w).WriteRecord(rec)
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := NewWriter(gz).WriteRecord(rec); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("gzip error: %w", err)
+	}
+
+	return nil
+}
+
+// append copies encoded records to the end of f. A failed append is cut off
+// again, so the file never ends in part of a record; a file that cannot be cut
+// is retired. mu must be held.
+func (self *fileManager) append(f *warcFile, members []Spool) ([]Location, error) {
+	start := f.size
+	locations := make([]Location, 0, len(members))
+	for _, member := range members {
+		r, err := member.Reader()
+		if err == nil {
+			_, err = io.Copy(f.file, r)
+		}
+		if err != nil {
+			f.size = start
+			if errTruncate := f.file.Truncate(start); errTruncate != nil {
+				f.broken = true
+				self.retire(f)
+			} else if _, errSeek := f.file.Seek(start, io.SeekStart); errSeek != nil {
+				f.broken = true
+				self.retire(f)
+			}
+			return nil, fmt.Errorf("write error: %w", err)
+		}
+
+		locations = append(locations, Location{Filename: f.filename, Offset: f.size, Length: member.Size()})
+		f.size += member.Size()
+	}
+
+	return locations, nil
+}
+
+// recoverOpenFiles finishes files left with the .open suffix by a run that did
+// not close them: each is cut after its last complete record and renamed, and
+// one without any is removed.
+func recoverOpenFiles(dir string, prefix string) error {
+	names, err := filepath.Glob(filepath.Join(dir, prefix+"-*.warc*.open"))
+	if err != nil {
+		return fmt.Errorf("list open files error: %w", err)
+	}
+
+	for _, name := range names {
+		end, err := completeLength(name)
+		if err != nil {
+			return err
+		}
+		if end == 0 {
+			if err := os.Remove(name); err != nil {
+				return fmt.Errorf("remove empty file error: %w", err)
+			}
+			continue
+		}
+		if err := os.Truncate(name, end); err != nil {
+			return fmt.Errorf("truncate file error: %w", err)
+		}
+		if err := os.Rename(name, strings.TrimSuffix(name, ".open")); err != nil {
+			return fmt.Errorf("rename file error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// completeLength returns where the last complete, intact record in a WARC
+// file ends.
+func completeLength(path string) (int64, error) {
+	r, err := OpenFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var end int64
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			break
+		}
+		if _, err := io.Copy(io.Discard, rec.Payload); err != nil {
+			break
+		}
+		loc := r.Location()
+		if loc.Length == 0 {
+			break
+		}
+		end = loc.Offset + loc.Length
+	}
+
+	return end, nil
+}
+
+func (self *fileManager) infoFields() string {
+	var sb strings.Builder
+	sb.WriteString("format: WARC File Format 1.1\r\n")
+	sb.WriteString("conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n")
+	if self.opts.Hostname != "" {
+		sb.WriteString("hostname: " + self.opts.Hostname + "\r\n")
+	}
+	self.opts.Info.WriteTo(&sb)
+
+	return sb.String()
+}
+
+func sanitizeFilenamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,260 @@
+package warc
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nlnwa/gowarc"
+)
+
+func readAllRecords(t *testing.T, path string) []gowarc.WarcRecord {
+	t.Helper()
+
+	r, err := gowarc.NewWarcFileReader(path, 0, gowarc.WithStrictValidation())
+	if err != nil {
+		t.Fatalf("NewWarcFileReader(%s) error = %v", path, err)
+	}
+	defer r.Close()
+
+	var res []gowarc.WarcRecord
+	for {
+		rec, _, validation, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next error = %v", err)
+		}
+		if !validation.Valid() {
+			t.Fatalf("validation errors: %v", validation)
+		}
+		res = append(res, rec)
+		rec.Close()
+	}
+
+	return res
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestFileManager_RollsOverAndStartsWithWarcinfo(t *testing.T) {
+	dir := t.TempDir()
+
+	var info Header
+	info.Set("software", "go-search-bot/test")
+
+	fm, err := NewFileManager(FileManagerOptions{
+		Dir:      dir,
+		Prefix:   "crawl",
+		Hostname: "host",
+		MaxSize:  1,
+		Compress: true,
+		Info:     info,
+	})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+
+	for range 3 {
+		locs, err := fm.WriteRecords(newResponseRecord(t, "<html></html>"))
+		if err != nil {
+			t.Fatalf("WriteRecords error = %v", err)
+		}
+		if len(locs) != 1 || locs[0].Offset == 0 || locs[0].Length == 0 {
+			t.Errorf("WriteRecords locations = %+v; want one record after warcinfo", locs)
+		}
+	}
+	if names := listFiles(t, dir); len(names) != 3 || !strings.HasSuffix(names[2], ".warc.gz.open") {
+		t.Errorf("files before Close = %v; want two closed files and one .open file", names)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if got := fm.Serial(); got != 3 {
+		t.Errorf("Serial = %d; want 3", got)
+	}
+
+	names := listFiles(t, dir)
+	if len(names) != 3 {
+		t.Fatalf("files = %v; want 3", names)
+	}
+	for i, name := range names {
+		if !strings.HasPrefix(name, "crawl-") || !strings.HasSuffix(name, "-host.warc.gz") {
+			t.Errorf("file name %q does not follow <prefix>-<timestamp>-<serial>-<host>.warc.gz", name)
+		}
+		if !strings.Contains(name, []string{"-00000-", "-00001-", "-00002-"}[i]) {
+			t.Errorf("file name %q has unexpected serial", name)
+		}
+
+		records := readAllRecords(t, filepath.Join(dir, name))
+		if len(records) != 2 {
+			t.Fatalf("%s has %d records; want 2", name, len(records))
+		}
+		if records[0].Type() != gowarc.Warcinfo {
+			t.Errorf("%s first record = %v; want warcinfo", name, records[0].Type())
+		}
+		if got := records[0].WarcHeader().Get(gowarc.WarcFilename); got != name {
+			t.Errorf("WARC-Filename = %q; want %q", got, name)
+		}
+		if got, want := records[1].WarcHeader().Get(gowarc.WarcWarcinfoID), records[0].WarcHeader().Get(gowarc.WarcRecordID); got != want {
+			t.Errorf("WARC-Warcinfo-ID = %q; want %q", got, want)
+		}
+	}
+}
+
+func TestFileManager_GzipMemberPerRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	fm, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	locs, err := fm.WriteRecords(newResponseRecord(t, "a"), newResponseRecord(t, "b"))
+	if err != nil {
+		t.Fatalf("WriteRecords error = %v", err)
+	}
+	fm.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, locs[1].Filename))
+	if err != nil {
+		t.Fatalf("ReadFile error = %v", err)
+	}
+	if locs[1].Offset != locs[0].Offset+locs[0].Length {
+		t.Errorf("records are not contiguous: %+v", locs)
+	}
+
+	member := data[locs[1].Offset : locs[1].Offset+locs[1].Length]
+	gz, err := gzip.NewReader(strings.NewReader(string(member)))
+	if err != nil {
+		t.Fatalf("gzip.NewReader error = %v", err)
+	}
+	gz.Multistream(false)
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("reading gzip member error = %v", err)
+	}
+	if !strings.HasPrefix(string(raw), "WARC/1.1\r\n") || !strings.HasSuffix(string(raw), "b\r\n\r\n") {
+		t.Errorf("gzip member does not hold exactly one record: %q", raw)
+	}
+}
+
+func TestFileManager_ConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+
+	fm, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", MaxSize: 4096, Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				if _, err := fm.WriteRecords(newResponseRecord(t, strings.Repeat("x", 100))); err != nil {
+					t.Errorf("WriteRecords error = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	responses := 0
+	for _, name := range listFiles(t, dir) {
+		for _, rec := range readAllRecords(t, filepath.Join(dir, name)) {
+			if rec.Type() == gowarc.Response {
+				responses++
+			}
+		}
+	}
+	if responses != 200 {
+		t.Errorf("read %d response records; want 200", responses)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestFileManager_FailedWriteLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+
+	fm, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	broken := newResponseRecord(t, "")
+	broken.Payload = failingReader{}
+	if _, err := fm.WriteRecords(newResponseRecord(t, "a"), broken); err == nil {
+		t.Fatal("WriteRecords with a failing payload error = nil; want an error")
+	}
+	locs, err := fm.WriteRecords(newResponseRecord(t, "b"))
+	if err != nil {
+		t.Fatalf("WriteRecords error = %v", err)
+	}
+	fm.Close()
+
+	records := readAllRecords(t, filepath.Join(dir, locs[0].Filename))
+	if len(records) != 2 || records[1].Type() != gowarc.Response {
+		t.Fatalf("file holds %d records; want warcinfo and the one successful write", len(records))
+	}
+}
+
+func TestFileManager_RecoversOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	fm, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	locs, err := fm.WriteRecords(newResponseRecord(t, "a"), newResponseRecord(t, "b"))
+	if err != nil {
+		t.Fatalf("WriteRecords error = %v", err)
+	}
+	// A crash in the middle of the second record leaves part of it behind.
+	path := filepath.Join(dir, locs[1].Filename+".open")
+	if err := os.Truncate(path, locs[1].Offset+locs[1].Length/2); err != nil {
+		t.Fatalf("Truncate error = %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "crawl-20250101000000-00009-host.warc.gz.open"), nil, 0644)
+
+	if _, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", Compress: true}); err != nil {
+		t.Fatalf("NewFileManager on leftover files error = %v", err)
+	}
+
+	names := listFiles(t, dir)
+	if len(names) != 1 || names[0] != locs[1].Filename {
+		t.Fatalf("files = %v; want only %s, renamed and the empty one removed", names, locs[1].Filename)
+	}
+	records := readAllRecords(t, filepath.Join(dir, names[0]))
+	if len(records) != 2 {
+		t.Errorf("recovered file holds %d records; want warcinfo and the complete response", len(records))
+	}
+}
@@ -0,0 +1,113 @@
+package warc
+
+import (
+	"io"
+	"strings"
+)
+
+const (
+	FieldType                  = "WARC-Type"
+	FieldRecordID              = "WARC-Record-ID"
+	FieldDate                  = "WARC-Date"
+	FieldContentLength         = "Content-Length"
+	FieldContentType           = "Content-Type"
+	FieldTargetURI             = "WARC-Target-URI"
+	FieldBlockDigest           = "WARC-Block-Digest"
+	FieldPayloadDigest         = "WARC-Payload-Digest"
+	FieldIPAddress             = "WARC-IP-Address"
+	FieldConcurrentTo          = "WARC-Concurrent-To"
+	FieldRefersTo              = "WARC-Refers-To"
+	FieldRefersToTargetURI     = "WARC-Refers-To-Target-URI"
+	FieldRefersToDate          = "WARC-Refers-To-Date"
+	FieldProfile               = "WARC-Profile"
+	FieldTruncated             = "WARC-Truncated"
+	FieldWarcinfoID            = "WARC-Warcinfo-ID"
+	FieldFilename              = "WARC-Filename"
+	FieldIdentifiedPayloadType = "WARC-Identified-Payload-Type"
+)
+
+type Field struct {
+	Name  string
+	Value string
+}
+
+type Header struct {
+	fields []Field
+}
+
+func (self *Header) Add(name string, value string) {
+	self.fields = append(self.fields, Field{Name: name, Value: value})
+}
+
+func (self *Header) Set(name string, value string) {
+	for i, f := range self.fields {
+		if strings.EqualFold(f.Name, name) {
+			self.fields[i].Value = value
+			self.deleteFrom(i+1, name)
+			return
+		}
+	}
+	self.Add(name, value)
+}
+
+func (self *Header) Get(name string) string {
+	for _, f := range self.fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+
+	return ""
+}
+
+func (self *Header) Has(name string) bool {
+	for _, f := range self.fields {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *Header) Values(name string) []string {
+	res := []string{}
+	for _, f := range self.fields {
+		if strings.EqualFold(f.Name, name) {
+			res = append(res, f.Value)
+		}
+	}
+
+	return res
+}
+
+func (self *Header) Del(name string) {
+	self.deleteFrom(0, name)
+}
+
+func (self *Header) Fields() []Field {
+	return append([]Field(nil), self.fields...)
+}
+
+func (self *Header) WriteTo(w io.Writer) (int64, error) {
+	n := int64(0)
+	for _, f := range self.fields {
+		m, err := io.WriteString(w, f.Name+": "+f.Value+"\r\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (self *Header) deleteFrom(start int, name string) {
+	kept := self.fields[:start]
+	for _, f := range self.fields[start:] {
+		if !strings.EqualFold(f.Name, name) {
+			kept = append(kept, f)
+		}
+	}
+	self.fields = kept
+}
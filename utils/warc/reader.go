@@ -0,0 +1,400 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+type Reader interface {
+	Next() (*Record, error)
+	Records() iter.Seq2[*Record, error]
+	Location() Location
+	Close() error
+}
+
+type reader struct {
+	filename string
+	closer   io.Closer
+
+	src    *countingReader
+	raw    *bufio.Reader
+	gz     *gzip.Reader
+	stream *bufio.Reader
+
+	memberStart int64
+	block       *verifyingReader
+	loc         Location
+	finalized   bool
+}
+
+func NewReader(r io.Reader) (Reader, error) {
+	src := &countingReader{r: r}
+	raw := bufio.NewReader(src)
+
+	self := &reader{
+		src: src,
+		raw: raw,
+	}
+
+	magic, err := raw.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			return nil, fmt.Errorf("gzip error: %w", err)
+		}
+		gz.Multistream(false)
+		self.gz = gz
+		self.stream = bufio.NewReader(gz)
+	} else {
+		self.stream = raw
+	}
+	self.finalized = true
+
+	return self, nil
+}
+
+func OpenFile(path string) (Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open error: %w", err)
+	}
+
+	r, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.(*reader).filename = filepath.Base(path)
+	r.(*reader).closer = file
+
+	return r, nil
+}
+
+func (self *reader) Next() (*Record, error) {
+	if err := self.finalize(); err != nil {
+		return nil, err
+	}
+
+	if self.gz != nil {
+		if _, err := self.stream.Peek(1); err == io.EOF {
+			if _, err := self.raw.Peek(1); err != nil {
+				return nil, io.EOF
+			}
+			self.memberStart = self.position()
+			if err := self.gz.Reset(self.raw); err != nil {
+				return nil, fmt.Errorf("gzip error: %w", err)
+			}
+			self.gz.Multistream(false)
+			self.stream.Reset(self.gz)
+		}
+	}
+
+	offset := self.position()
+	if self.gz != nil {
+		offset = self.memberStart
+	}
+
+	rec, err := self.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseInt(rec.Header.Get(FieldContentLength), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", rec.Header.Get(FieldContentLength))
+	}
+
+	blockHash, blockDigest := newDigestHash(rec.Header.Get(FieldBlockDigest))
+	content := io.Reader(io.LimitReader(self.stream, length))
+	if blockHash != nil {
+		content = io.TeeReader(content, blockHash)
+	}
+	block := bufio.NewReader(content)
+
+	if hasHTTPBlock(rec) {
+		httpHeader, err := readHTTPHeader(block)
+		if err != nil {
+			return nil, err
+		}
+		rec.HTTPHeader = httpHeader
+	}
+
+	var payloadHash hash.Hash
+	var payloadDigest string
+	payload := io.Reader(block)
+	if rec.HTTPHeader != nil && rec.Type() != TypeRevisit {
+		payloadHash, payloadDigest = newDigestHash(rec.Header.Get(FieldPayloadDigest))
+		if payloadHash != nil {
+			payload = io.TeeReader(payload, payloadHash)
+		}
+	}
+
+	self.block = &verifyingReader{
+		r: payload,
+		check: func() error {
+			if blockHash != nil && !digestMatches(blockHash, blockDigest) {
+				return fmt.Errorf("block of %s: %w", rec.ID(), ErrDigestMismatch)
+			}
+			if payloadHash != nil && !digestMatches(payloadHash, payloadDigest) {
+				return fmt.Errorf("payload of %s: %w", rec.ID(), ErrDigestMismatch)
+			}
+			return nil
+		},
+	}
+
+	rec.Payload = self.block
+	self.loc = Location{Filename: self.filename, Offset: offset}
+	self.finalized = false
+
+	return rec, nil
+}
+
+func (self *reader) Records() iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		for {
+			rec, err := self.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(rec, err) {
+				return
+			}
+			if err != nil && !errors.Is(err, ErrDigestMismatch) {
+				return
+			}
+		}
+	}
+}
+
+func (self *reader) Location() Location {
+	self.finalize()
+
+	return self.loc
+}
+
+func (self *reader) Close() error {
+	var errs []error
+	if self.gz != nil {
+		errs = append(errs, self.gz.Close())
+	}
+	if self.closer != nil {
+		errs = append(errs, self.closer.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (self *reader) finalize() error {
+	if self.finalized {
+		return nil
+	}
+	self.finalized = true
+
+	reported := self.block.err != nil
+	_, errDrain := io.Copy(io.Discard, self.block)
+	if reported {
+		errDrain = nil
+	}
+
+	for range 4 {
+		b, err := self.stream.ReadByte()
+		if err != nil {
+			break
+		}
+		if b != '\r' && b != '\n' {
+			self.stream.UnreadByte()
+			break
+		}
+	}
+
+	if self.gz == nil {
+		self.loc.Length = self.position() - self.loc.Offset
+	} else if _, err := self.stream.Peek(1); err == io.EOF {
+		self.loc.Length = self.position() - self.loc.Offset
+	}
+
+	return errDrain
+}
+
+func (self *reader) position() int64 {
+	return self.src.n - int64(self.raw.Buffered())
+}
+
+func (self *reader) readHeader() (*Record, error) {
+	var line string
+	for {
+		l, err := self.stream.ReadString('\n')
+		if err == io.EOF && l == "" {
+			return nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read error: %w", err)
+		}
+		line = strings.TrimRight(l, "\r\n")
+		if line != "" {
+			break
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("invalid version line %q", line)
+	}
+
+	rec := &Record{}
+	for {
+		l, err := self.stream.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unexpected end of header: %w", err)
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" {
+			break
+		}
+
+		if (l[0] == ' ' || l[0] == '\t') && len(rec.Header.fields) > 0 {
+			last := &rec.Header.fields[len(rec.Header.fields)-1]
+			last.Value += " " + strings.TrimSpace(l)
+			continue
+		}
+
+		name, value, ok := strings.Cut(l, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header line %q", l)
+		}
+		rec.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	if !rec.Header.Has(FieldContentLength) {
+		return nil, errors.New("record has no Content-Length")
+	}
+
+	return rec, nil
+}
+
+func (self *Record) HTTPResponse() (*http.Response, error) {
+	if self.HTTPHeader == nil {
+		return nil, errors.New("record has no HTTP header")
+	}
+
+	body := io.Reader(bytes.NewReader(nil))
+	if self.Payload != nil {
+		body = self.Payload
+	}
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(self.HTTPHeader), body))
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse HTTP response error: %w", err)
+	}
+
+	return resp, nil
+}
+
+func hasHTTPBlock(rec *Record) bool {
+	switch rec.Type() {
+	case TypeResponse, TypeRequest, TypeRevisit:
+		return strings.HasPrefix(strings.ToLower(rec.Header.Get(FieldContentType)), "application/http")
+	default:
+		return false
+	}
+}
+
+func readHTTPHeader(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		buf.WriteString(line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read HTTP header error: %w", err)
+		}
+		if line == "\r\n" || line == "\n" {
+			break
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func newDigestHash(digest string) (hash.Hash, string) {
+	algorithm, value, ok := strings.Cut(digest, ":")
+	if !ok {
+		return nil, ""
+	}
+
+	switch strings.ToLower(strings.ReplaceAll(algorithm, "-", "")) {
+	case "sha1":
+		return sha1.New(), value
+	case "sha256":
+		return sha256.New(), value
+	case "md5":
+		return md5.New(), value
+	default:
+		return nil, ""
+	}
+}
+
+func digestMatches(h hash.Hash, value string) bool {
+	sum := h.Sum(nil)
+
+	return strings.EqualFold(value, base32.StdEncoding.EncodeToString(sum)) ||
+		strings.EqualFold(value, hex.EncodeToString(sum))
+}
+
+type verifyingReader struct {
+	r     io.Reader
+	check func() error
+	err   error
+}
+
+func (self *verifyingReader) Read(p []byte) (int, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+
+	n, err := self.r.Read(p)
+	if err == io.EOF {
+		if errCheck := self.check(); errCheck != nil {
+			err = errCheck
+		}
+		self.err = err
+	} else if err != nil {
+		self.err = err
+	}
+
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (self *countingReader) Read(p []byte) (int, error) {
+	n, err := self.r.Read(p)
+	self.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,193 @@
+package warc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReader_RoundTripUncompressed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	const body = "<html><body>round trip</body></html>"
+	response := newResponseRecord(t, body)
+	if _, err := w.WriteRecord(response); err != nil {
+		t.Fatalf("WriteRecord error = %v", err)
+	}
+	first := int64(buf.Len())
+
+	var fields Header
+	fields.Add("outlink", "https://example.com/a")
+	if _, err := w.WriteRecord(NewMetadataRecord("https://example.com/", fields)); err != nil {
+		t.Fatalf("WriteRecord error = %v", err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	defer r.Close()
+
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next error = %v", err)
+	}
+	if rec.Type() != TypeResponse || rec.ID() != response.ID() {
+		t.Errorf("record = %s %s; want response %s", rec.Type(), rec.ID(), response.ID())
+	}
+
+	resp, err := rec.HTTPResponse()
+	if err != nil {
+		t.Fatalf("HTTPResponse error = %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("HTTP response = %d %v; want 200 text/html", resp.StatusCode, resp.Header)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body error = %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("body = %q; want %q", got, body)
+	}
+	if loc := r.Location(); loc.Offset != 0 || loc.Length != first {
+		t.Errorf("Location = %+v; want offset 0 length %d", loc, first)
+	}
+
+	rec, err = r.Next()
+	if err != nil {
+		t.Fatalf("Next error = %v", err)
+	}
+	payload, _ := io.ReadAll(rec.Payload)
+	if rec.Type() != TypeMetadata || string(payload) != "outlink: https://example.com/a\r\n" {
+		t.Errorf("metadata record = %s %q", rec.Type(), payload)
+	}
+	if loc := r.Location(); loc.Offset != first || loc.Offset+loc.Length != int64(buf.Len()) {
+		t.Errorf("Location = %+v; want offset %d ending at %d", loc, first, buf.Len())
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next at end = %v; want io.EOF", err)
+	}
+}
+
+func TestReader_GzipFileLocationsAndIterator(t *testing.T) {
+	dir := t.TempDir()
+
+	fm, err := NewFileManager(FileManagerOptions{Dir: dir, Prefix: "crawl", Compress: true})
+	if err != nil {
+		t.Fatalf("NewFileManager error = %v", err)
+	}
+	locs, err := fm.WriteRecords(newResponseRecord(t, "one"), newResponseRecord(t, "two"), newResponseRecord(t, "three"))
+	if err != nil {
+		t.Fatalf("WriteRecords error = %v", err)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	r, err := OpenFile(filepath.Join(dir, locs[0].Filename))
+	if err != nil {
+		t.Fatalf("OpenFile error = %v", err)
+	}
+	defer r.Close()
+
+	var bodies []string
+	var got []Location
+	for rec, err := range r.Records() {
+		if err != nil {
+			t.Fatalf("Records error = %v", err)
+		}
+		if rec.Type() != TypeResponse {
+			continue
+		}
+		payload, err := io.ReadAll(rec.Payload)
+		if err != nil {
+			t.Fatalf("reading payload error = %v", err)
+		}
+		bodies = append(bodies, string(payload))
+		got = append(got, r.Location())
+	}
+
+	if strings.Join(bodies, ",") != "one,two,three" {
+		t.Errorf("bodies = %v; want [one two three]", bodies)
+	}
+	for i := range locs {
+		if got[i] != locs[i] {
+			t.Errorf("Location[%d] = %+v; want %+v", i, got[i], locs[i])
+		}
+	}
+}
+
+func TestReader_DigestMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.WriteRecord(newResponseRecord(t, "original")); err != nil {
+		t.Fatalf("WriteRecord error = %v", err)
+	}
+	if _, err := w.WriteRecord(newResponseRecord(t, "second")); err != nil {
+		t.Fatalf("WriteRecord error = %v", err)
+	}
+	tampered := bytes.Replace(buf.Bytes(), []byte("original"), []byte("tampered"), 1)
+
+	r, err := NewReader(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+
+	var errs []error
+	records := 0
+	for rec, err := range r.Records() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		records++
+		if _, err := io.ReadAll(rec.Payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if records != 2 {
+		t.Errorf("read %d records; want 2", records)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDigestMismatch) {
+		t.Errorf("errors = %v; want one ErrDigestMismatch", errs)
+	}
+}
+
+func TestReader_UnreadPayloadIsVerifiedOnNext(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf).WriteRecord(newResponseRecord(t, "original")); err != nil {
+		t.Fatalf("WriteRecord error = %v", err)
+	}
+	tampered := bytes.Replace(buf.Bytes(), []byte("original"), []byte("tampered"), 1)
+
+	r, err := NewReader(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("Next error = %v", err)
+	}
+	if _, err := r.Next(); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Next after unread tampered record = %v; want ErrDigestMismatch", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next at end = %v; want io.EOF", err)
+	}
+}
+
+func TestReader_InvalidInput(t *testing.T) {
+	r, err := NewReader(strings.NewReader("HTTP/1.1 200 OK\r\n\r\n"))
+	if err != nil {
+		t.Fatalf("NewReader error = %v", err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("Next on non-WARC input = %v; want parse error", err)
+	}
+}
@@ -0,0 +1,228 @@
+package warc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const Version = "WARC/1.1"
+
+type RecordType string
+
+const (
+	TypeWarcinfo     RecordType = "warcinfo"
+	TypeResponse     RecordType = "response"
+	TypeResource     RecordType = "resource"
+	TypeRequest      RecordType = "request"
+	TypeMetadata     RecordType = "metadata"
+	TypeRevisit      RecordType = "revisit"
+	TypeConversion   RecordType = "conversion"
+	TypeContinuation RecordType = "continuation"
+)
+
+const (
+	ProfileIdenticalPayloadDigest = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+	ProfileServerNotModified      = "http://netpreserve.org/warc/1.1/revisit/server-not-modified"
+)
+
+const (
+	TruncatedLength      = "length"
+	TruncatedTime        = "time"
+	TruncatedDisconnect  = "disconnect"
+	TruncatedUnspecified = "unspecified"
+)
+
+const dateLayout = "2006-01-02T15:04:05Z"
+
+type Record struct {
+	Header     Header
+	HTTPHeader []byte
+	Payload    io.Reader
+}
+
+func NewRecord(recordType RecordType) *Record {
+	rec := &Record{}
+	rec.Header.Set(FieldType, string(recordType))
+	rec.Header.Set(FieldRecordID, NewRecordID())
+	rec.Header.Set(FieldDate, FormatDate(time.Now()))
+
+	return rec
+}
+
+func NewRequestRecord(targetURI string, req *http.Request) *Record {
+	rec := NewRecord(TypeRequest)
+	rec.Header.Set(FieldTargetURI, targetURI)
+	rec.Header.Set(FieldContentType, "application/http; msgtype=request")
+	rec.HTTPHeader = HTTPRequestHeader(req)
+
+	return rec
+}
+
+func NewMetadataRecord(targetURI string, fields Header) *Record {
+	var buf bytes.Buffer
+	fields.WriteTo(&buf)
+
+	rec := NewRecord(TypeMetadata)
+	rec.Header.Set(FieldTargetURI, targetURI)
+	rec.Header.Set(FieldContentType, "application/warc-fields")
+	rec.Payload = &buf
+
+	return rec
+}
+
+// NewConversionRecord holds content derived from the record it refers to,
+// such as the text extracted from an HTML response.
+func NewConversionRecord(targetURI string, refersTo *Record, contentType string, payload io.Reader) *Record {
+	rec := NewRecord(TypeConversion)
+	rec.Header.Set(FieldTargetURI, targetURI)
+	rec.Header.Set(FieldRefersTo, refersTo.ID())
+	rec.Header.Set(FieldContentType, contentType)
+	rec.Payload = payload
+
+	return rec
+}
+
+func NewRevisitRecord(targetURI string, profile string, refersTo *Record) *Record {
+	rec := NewRecord(TypeRevisit)
+	rec.Header.Set(FieldTargetURI, targetURI)
+	rec.Header.Set(FieldProfile, profile)
+	rec.Header.Set(FieldContentType, "application/http; msgtype=response")
+	rec.Header.Set(FieldRefersTo, refersTo.ID())
+	rec.Header.Set(FieldRefersToTargetURI, refersTo.Header.Get(FieldTargetURI))
+	rec.Header.Set(FieldRefersToDate, refersTo.Header.Get(FieldDate))
+	if digest := refersTo.Header.Get(FieldPayloadDigest); digest != "" {
+		rec.Header.Set(FieldPayloadDigest, digest)
+	}
+
+	return rec
+}
+
+func (self *Record) Type() RecordType {
+	return RecordType(self.Header.Get(FieldType))
+}
+
+func (self *Record) ID() string {
+	return self.Header.Get(FieldRecordID)
+}
+
+func NewRecordID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(dateLayout)
+}
+
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid WARC-Date %q: %w", s, err)
+	}
+
+	return t, nil
+}
+
+func Digest(b []byte) string {
+	sum := sha1.Sum(b)
+
+	return FormatDigest(sum[:])
+}
+
+func FormatDigest(sum []byte) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum)
+}
+
+func HTTPRequestHeader(req *http.Request) []byte {
+	var buf bytes.Buffer
+
+	proto := req.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	fmt.Fprintf(&buf, "%s %s %s\r\n", method, req.URL.RequestURI(), proto)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	req.Header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// DechunkedHeader rewrites a response header block received on the wire for
+// a payload stored without its transfer coding, as net/http hands bodies over
+// de-chunked: Transfer-Encoding is dropped and Content-Length is set to the
+// stored length, so the record reads back as a complete message. Everything
+// else in the block is kept as it was.
+func DechunkedHeader(block []byte, length int64) []byte {
+	eol := "\n"
+	if bytes.Contains(block, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+
+	var buf bytes.Buffer
+	dropped := false
+	for i, line := range strings.SplitAfter(string(block), "\n") {
+		field := strings.TrimRight(line, "\r\n")
+		switch {
+		case i == 0:
+			buf.WriteString(line)
+		case field == "":
+			fmt.Fprintf(&buf, "Content-Length: %d%s", length, eol)
+			buf.WriteString(line)
+			return buf.Bytes()
+		case field[0] == ' ' || field[0] == '\t':
+			if !dropped {
+				buf.WriteString(line)
+			}
+		default:
+			name, _, _ := strings.Cut(field, ":")
+			name = strings.TrimSpace(name)
+			dropped = strings.EqualFold(name, "Transfer-Encoding") || strings.EqualFold(name, "Content-Length")
+			if !dropped {
+				buf.WriteString(line)
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func HTTPResponseHeader(resp *http.Response) []byte {
+	var buf bytes.Buffer
+
+	proto := resp.Proto
+	if proto == "" {
+		proto = fmt.Sprintf("HTTP/%d.%d", resp.ProtoMajor, resp.ProtoMinor)
+	}
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	fmt.Fprintf(&buf, "%s %s\r\n", proto, status)
+	resp.Header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,80 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+const spoolMemoryLimit = 1 << 20
+
+// Spool holds a stream that has to be read more than once, such as a payload
+// whose digest is needed before it is written. The first MiB stays in memory
+// and the rest goes to a temporary file, removed by Close.
+type Spool interface {
+	io.Writer
+	Size() int64
+	// Reader reads the spool from the start; the readers it returns share
+	// one position, so only the latest may be used.
+	Reader() (io.Reader, error)
+	Close() error
+}
+
+func NewSpool() Spool {
+	return &spool{}
+}
+
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func (self *spool) Write(p []byte) (int, error) {
+	if self.file == nil && self.buf.Len()+len(p) > spoolMemoryLimit {
+		file, err := os.CreateTemp("", "warc-spool-*")
+		if err != nil {
+			return 0, fmt.Errorf("create spool error: %w", err)
+		}
+		self.file = file
+		if _, err := self.buf.WriteTo(file); err != nil {
+			return 0, fmt.Errorf("write spool error: %w", err)
+		}
+	}
+
+	var n int
+	var err error
+	if self.file != nil {
+		n, err = self.file.Write(p)
+	} else {
+		n, err = self.buf.Write(p)
+	}
+	self.size += int64(n)
+
+	return n, err
+}
+
+func (self *spool) Size() int64 {
+	return self.size
+}
+
+func (self *spool) Reader() (io.Reader, error) {
+	if self.file == nil {
+		return bytes.NewReader(self.buf.Bytes()), nil
+	}
+	if _, err := self.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewind spool error: %w", err)
+	}
+
+	return self.file, nil
+}
+
+func (self *spool) Close() error {
+	if self.file == nil {
+		return nil
+	}
+	self.file.Close()
+
+	return os.Remove(self.file.Name())
+}
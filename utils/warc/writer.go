@@ -0,0 +1,94 @@
+package warc
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Writer interface {
+	WriteRecord(*Record) (int64, error)
+}
+
+type writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) Writer {
+	return &writer{
+		w: w,
+	}
+}
+
+func (self *writer) WriteRecord(rec *Record) (int64, error) {
+	if rec.Header.Get(FieldType) == "" {
+		return 0, errors.New("record has no WARC-Type")
+	}
+	for _, f := range rec.Header.fields {
+		if f.Name == "" || strings.ContainsAny(f.Name, ":\r\n") || strings.ContainsAny(f.Value, "\r\n") {
+			return 0, fmt.Errorf("invalid header field %q", f.Name)
+		}
+	}
+	if !rec.Header.Has(FieldRecordID) {
+		rec.Header.Set(FieldRecordID, NewRecordID())
+	}
+	if !rec.Header.Has(FieldDate) {
+		rec.Header.Set(FieldDate, FormatDate(time.Now()))
+	}
+
+	var payload spool
+	defer payload.Close()
+
+	block := sha1.New()
+	block.Write(rec.HTTPHeader)
+	payloadDigest := sha1.New()
+	if rec.Payload != nil {
+		if _, err := io.Copy(io.MultiWriter(&payload, block, payloadDigest), rec.Payload); err != nil {
+			return 0, fmt.Errorf("read payload error: %w", err)
+		}
+	}
+	rec.Header.Set(FieldBlockDigest, FormatDigest(block.Sum(nil)))
+
+	if rec.HTTPHeader != nil && rec.Type() != TypeRevisit {
+		rec.Header.Set(FieldPayloadDigest, FormatDigest(payloadDigest.Sum(nil)))
+	}
+
+	length := int64(len(rec.HTTPHeader)) + payload.Size()
+	rec.Header.Set(FieldContentLength, strconv.FormatInt(length, 10))
+
+	payloadReader, err := payload.Reader()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(self.w)
+	n := int64(0)
+	write := func(b []byte) {
+		m, _ := bw.Write(b)
+		n += int64(m)
+	}
+
+	write([]byte(Version + "\r\n"))
+	for _, f := range rec.Header.fields {
+		write([]byte(f.Name + ": " + f.Value + "\r\n"))
+	}
+	write([]byte("\r\n"))
+	write(rec.HTTPHeader)
+	m, err := io.Copy(bw, payloadReader)
+	n += m
+	if err != nil {
+		return n, fmt.Errorf("write error: %w", err)
+	}
+	write([]byte("\r\n\r\n"))
+
+	if err := bw.Flush(); err != nil {
+		return n, fmt.Errorf("write error: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,327 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nlnwa/gowarc"
+)
+
+func newResponseRecord(t *testing.T, body string) *Record {
+	t.Helper()
+
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":   {"text/html; charset=utf-8"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+	}
+
+	rec := NewRecord(TypeResponse)
+	rec.Header.Set(FieldTargetURI, "https://example.com/")
+	rec.Header.Set(FieldContentType, "application/http; msgtype=response")
+	rec.HTTPHeader = HTTPResponseHeader(resp)
+	rec.Payload = strings.NewReader(body)
+
+	return rec
+}
+
+func TestWriteRecord_ValidatesWithGowarc(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	const body = "<html><body>hello</body></html>"
+	n, err := w.WriteRecord(newResponseRecord(t, body))
+	if err != nil {
+		t.Fatalf("WriteRecord error = %v; want nil", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteRecord returned %d bytes; buffer has %d", n, buf.Len())
+	}
+
+	meta := NewRecord(TypeMetadata)
+	meta.Header.Set(FieldTargetURI, "https://example.com/")
+	meta.Header.Set(FieldContentType, "application/warc-fields")
+	meta.Payload = strings.NewReader("outlink: https://example.com/about\r\n")
+	if _, err := w.WriteRecord(meta); err != nil {
+		t.Fatalf("WriteRecord(metadata) error = %v; want nil", err)
+	}
+
+	u := gowarc.NewUnmarshaler(gowarc.WithStrictValidation())
+	r := bufio.NewReader(&buf)
+
+	rec, _, validation, err := u.Unmarshal(r)
+	if err != nil {
+		t.Fatalf("Unmarshal error = %v; want nil", err)
+	}
+	if !validation.Valid() {
+		t.Fatalf("validation errors: %v", validation)
+	}
+	if rec.Type() != gowarc.Response {
+		t.Errorf("record type = %v; want response", rec.Type())
+	}
+
+	block, ok := rec.Block().(gowarc.HttpResponseBlock)
+	if !ok {
+		t.Fatalf("block type = %T; want HttpResponseBlock", rec.Block())
+	}
+	if got := block.HttpStatusLine(); got != "200 OK" {
+		t.Errorf("status line = %q; want %q", got, "200 OK")
+	}
+	payload, err := block.PayloadBytes()
+	if err != nil {
+		t.Fatalf("PayloadBytes error = %v", err)
+	}
+	got, _ := io.ReadAll(payload)
+	if string(got) != body {
+		t.Errorf("payload = %q; want %q", got, body)
+	}
+	if want := Digest([]byte(body)); rec.WarcHeader().Get(gowarc.WarcPayloadDigest) != want {
+		t.Errorf("payload digest = %q; want %q", rec.WarcHeader().Get(gowarc.WarcPayloadDigest), want)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+
+	rec, _, validation, err = u.Unmarshal(r)
+	if err != nil {
+		t.Fatalf("Unmarshal(metadata) error = %v; want nil", err)
+	}
+	if !validation.Valid() {
+		t.Fatalf("validation errors: %v", validation)
+	}
+	if rec.Type() != gowarc.Metadata {
+		t.Errorf("record type = %v; want metadata", rec.Type())
+	}
+	rec.Close()
+
+	if _, _, _, err := u.Unmarshal(r); err != io.EOF {
+		t.Errorf("Unmarshal at end = %v; want io.EOF", err)
+	}
+}
+
+func TestWriteRecord_ExactLayout(t *testing.T) {
+	var buf bytes.Buffer
+
+	rec := &Record{}
+	rec.Header.Set(FieldType, string(TypeResource))
+	rec.Header.Set(FieldRecordID, "<urn:uuid:00000000-0000-4000-8000-000000000000>")
+	rec.Header.Set(FieldDate, "2025-01-02T03:04:05Z")
+	rec.Payload = strings.NewReader("abc")
+
+	if _, err := NewWriter(&buf).WriteRecord(rec); err != nil {
+		t.Fatalf("WriteRecord error = %v; want nil", err)
+	}
+
+	want := "WARC/1.1\r\n" +
+		"WARC-Type: resource\r\n" +
+		"WARC-Record-ID: <urn:uuid:00000000-0000-4000-8000-000000000000>\r\n" +
+		"WARC-Date: 2025-01-02T03:04:05Z\r\n" +
+		"WARC-Block-Digest: " + Digest([]byte("abc")) + "\r\n" +
+		"Content-Length: 3\r\n" +
+		"\r\n" +
+		"abc\r\n\r\n"
+	if buf.String() != want {
+		t.Errorf("WriteRecord output =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestWriteRecord_Errors(t *testing.T) {
+	w := NewWriter(io.Discard)
+
+	if _, err := w.WriteRecord(&Record{}); err == nil {
+		t.Error("expected error for record without WARC-Type")
+	}
+
+	rec := NewRecord(TypeResource)
+	rec.Header.Set("X-Bad", "line\r\nbreak")
+	if _, err := w.WriteRecord(rec); err == nil {
+		t.Error("expected error for header value containing CRLF")
+	}
+}
+
+func TestHeader_SetGetDel(t *testing.T) {
+	var h Header
+	h.Add("A", "1")
+	h.Add("b", "2")
+	h.Add("a", "3")
+
+	if got := h.Values("a"); len(got) != 2 {
+		t.Errorf("Values(\"a\") = %v; want two values", got)
+	}
+
+	h.Set("A", "4")
+	if got := h.Values("a"); len(got) != 1 || got[0] != "4" {
+		t.Errorf("after Set, Values(\"a\") = %v; want [4]", got)
+	}
+	if got := h.Fields(); got[0].Name != "A" || got[1].Name != "b" {
+		t.Errorf("Set should keep field order, got %v", got)
+	}
+
+	h.Del("B")
+	if h.Has("b") {
+		t.Error("Has(\"b\") = true after Del")
+	}
+}
+
+func TestNewRecordID(t *testing.T) {
+	id := NewRecordID()
+	if !strings.HasPrefix(id, "<urn:uuid:") || !strings.HasSuffix(id, ">") || len(id) != 47 {
+		t.Errorf("NewRecordID = %q; want <urn:uuid:...> form", id)
+	}
+	if id == NewRecordID() {
+		t.Error("NewRecordID returned the same id twice")
+	}
+}
+
+func TestWriteRecord_LinkedRecordSet(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	response := newResponseRecord(t, "<html></html>")
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/path?q=1", nil)
+	if err != nil {
+		t.Fatalf("NewRequest error = %v", err)
+	}
+	req.Header.Set("User-Agent", "go-search-bot/test")
+	request := NewRequestRecord("https://example.com/path?q=1", req)
+	request.Header.Set(FieldConcurrentTo, response.ID())
+
+	var fields Header
+	fields.Add("via", "https://example.com/")
+	fields.Add("outlink", "https://example.com/about")
+	metadata := NewMetadataRecord("https://example.com/path?q=1", fields)
+	metadata.Header.Set(FieldConcurrentTo, response.ID())
+
+	for _, rec := range []*Record{response, request, metadata} {
+		if _, err := w.WriteRecord(rec); err != nil {
+			t.Fatalf("WriteRecord(%s) error = %v", rec.Type(), err)
+		}
+	}
+
+	revisit := NewRevisitRecord("https://example.com/path?q=1", ProfileIdenticalPayloadDigest, response)
+	revisit.HTTPHeader = response.HTTPHeader
+	if _, err := w.WriteRecord(revisit); err != nil {
+		t.Fatalf("WriteRecord(revisit) error = %v", err)
+	}
+
+	conversion := NewConversionRecord("https://example.com/path?q=1", response, "application/json", strings.NewReader(`{"text":""}`))
+	if _, err := w.WriteRecord(conversion); err != nil {
+		t.Fatalf("WriteRecord(conversion) error = %v", err)
+	}
+
+	u := gowarc.NewUnmarshaler(gowarc.WithStrictValidation())
+	r := bufio.NewReader(&buf)
+	wantTypes := []gowarc.RecordType{gowarc.Response, gowarc.Request, gowarc.Metadata, gowarc.Revisit, gowarc.Conversion}
+	for _, want := range wantTypes {
+		rec, _, validation, err := u.Unmarshal(r)
+		if err != nil {
+			t.Fatalf("Unmarshal(%v) error = %v", want, err)
+		}
+		if !validation.Valid() {
+			t.Fatalf("validation errors for %v: %v", want, validation)
+		}
+		if rec.Type() != want {
+			t.Errorf("record type = %v; want %v", rec.Type(), want)
+		}
+
+		switch want {
+		case gowarc.Request:
+			block, ok := rec.Block().(gowarc.HttpRequestBlock)
+			if !ok {
+				t.Fatalf("request block type = %T", rec.Block())
+			}
+			if got := block.HttpRequestLine(); !strings.Contains(got, "/path?q=1") {
+				t.Errorf("request line = %q", got)
+			}
+			if got := block.HttpHeader().Get("User-Agent"); got != "go-search-bot/test" {
+				t.Errorf("request User-Agent = %q", got)
+			}
+			if got := rec.WarcHeader().Get(gowarc.WarcConcurrentTo); got != response.ID() {
+				t.Errorf("WARC-Concurrent-To = %q; want %q", got, response.ID())
+			}
+		case gowarc.Revisit:
+			if got := rec.WarcHeader().Get(gowarc.WarcRefersTo); got != response.ID() {
+				t.Errorf("WARC-Refers-To = %q; want %q", got, response.ID())
+			}
+			if got := rec.WarcHeader().Get(gowarc.WarcPayloadDigest); got != response.Header.Get(FieldPayloadDigest) {
+				t.Errorf("revisit payload digest = %q; want original %q", got, response.Header.Get(FieldPayloadDigest))
+			}
+		case gowarc.Conversion:
+			if got := rec.WarcHeader().Get(gowarc.WarcRefersTo); got != response.ID() {
+				t.Errorf("conversion WARC-Refers-To = %q; want %q", got, response.ID())
+			}
+		}
+		rec.Close()
+	}
+}
+
+func TestWriteRecord_LargePayloadSpooledAndTruncated(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", spoolMemoryLimit/16*3)
+	rec := newResponseRecord(t, body)
+	rec.Header.Set(FieldTruncated, TruncatedLength)
+
+	var buf bytes.Buffer
+	n, err := NewWriter(&buf).WriteRecord(rec)
+	if err != nil {
+		t.Fatalf("WriteRecord error = %v; want nil", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteRecord returned %d bytes; buffer has %d", n, buf.Len())
+	}
+	if want := strconv.Itoa(len(rec.HTTPHeader) + len(body)); rec.Header.Get(FieldContentLength) != want {
+		t.Errorf("Content-Length = %s; want %s", rec.Header.Get(FieldContentLength), want)
+	}
+	if rec.Header.Get(FieldPayloadDigest) != Digest([]byte(body)) {
+		t.Errorf("Payload-Digest = %s; want %s", rec.Header.Get(FieldPayloadDigest), Digest([]byte(body)))
+	}
+
+	got, _, validation, err := gowarc.NewUnmarshaler(gowarc.WithStrictValidation()).Unmarshal(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("Unmarshal error = %v; want nil", err)
+	}
+	if !validation.Valid() {
+		t.Fatalf("validation errors: %v", validation)
+	}
+	if got.WarcHeader().Get(gowarc.WarcTruncated) != TruncatedLength {
+		t.Errorf("WARC-Truncated = %q; want %q", got.WarcHeader().Get(gowarc.WarcTruncated), TruncatedLength)
+	}
+	payload, err := got.Block().(gowarc.HttpResponseBlock).PayloadBytes()
+	if err != nil {
+		t.Fatalf("PayloadBytes error = %v", err)
+	}
+	data, _ := io.ReadAll(payload)
+	if string(data) != body {
+		t.Errorf("payload length = %d; want %d", len(data), len(body))
+	}
+}
+
+func TestDechunkedHeader(t *testing.T) {
+	block := "HTTP/1.1 200 OK\r\nContent-type: text/html\r\ntransfer-encoding:\r\n chunked\r\nX-A: 1\r\nX-A: 2\r\n\r\n"
+	got := string(DechunkedHeader([]byte(block), 11))
+	want := "HTTP/1.1 200 OK\r\nContent-type: text/html\r\nX-A: 1\r\nX-A: 2\r\nContent-Length: 11\r\n\r\n"
+	if got != want {
+		t.Fatalf("DechunkedHeader = %q; want %q", got, want)
+	}
+
+	rec := NewRecord(TypeResponse)
+	rec.HTTPHeader = []byte(got)
+	rec.Payload = strings.NewReader("hello world")
+	resp, err := rec.HTTPResponse()
+	if err != nil {
+		t.Fatalf("HTTPResponse error = %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "hello world" {
+		t.Errorf("body = (%q, %v); want hello world", body, err)
+	}
+}